@@ -0,0 +1,239 @@
+// Package archive provides a portable, tar based archive format to export and import the content of any
+// CrudRepository or BlobRepository, independent of the storage layout of the actual implementation.
+//
+// An archive contains the following tar entries in order:
+//
+//	HEADER.json          format, version, type name and codec of the entries
+//	entries/<n>          one entry per entity or blob, with the json encoded ID and the sha256 of the content
+//	                     as PAX records
+//	MANIFEST.json        the amount of entries and a sha256 over all entry checksums
+//
+// Because the manifest is written last, an archive can be exported in a single streaming pass.
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	format  = "golangee/repository/archive"
+	version = 1
+
+	headerName   = "HEADER.json"
+	manifestName = "MANIFEST.json"
+	entryPrefix  = "entries/"
+
+	paxID     = "GOLANGEE.id"
+	paxSHA256 = "GOLANGEE.sha256"
+
+	CodecJSON = "json" // CodecJSON denotes json encoded entities of a CrudRepository.
+	CodecRaw  = "raw"  // CodecRaw denotes the unmodified bytes of a BlobRepository.
+)
+
+// ChecksumError is returned, if the content of an archive does not match its recorded checksums.
+var ChecksumError = errors.New("archive checksum mismatch")
+
+// Header describes the content of an archive.
+type Header struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	Type    string `json:"type"`  // Type is the Go type name of the entities or "blob".
+	Codec   string `json:"codec"` // Codec is either CodecJSON or CodecRaw.
+}
+
+// Manifest summarizes the content of an archive.
+type Manifest struct {
+	Count  int64  `json:"count"`  // Count is the amount of entries.
+	SHA256 string `json:"sha256"` // SHA256 is the hex encoded hash over the raw checksums of all entries in order.
+}
+
+// writer writes the common structure of an archive.
+type writer struct {
+	tw     *tar.Writer
+	count  int64
+	digest hash.Hash
+}
+
+func newWriter(w io.Writer, header Header) (*writer, error) {
+	aw := &writer{tw: tar.NewWriter(w), digest: sha256.New()}
+	header.Format = format
+	header.Version = version
+	if err := aw.writeJSON(headerName, header); err != nil {
+		return nil, err
+	}
+
+	return aw, nil
+}
+
+// writeEntry writes the next entry. The caller must provide the size and checksum in advance, as required by tar.
+func (w *writer) writeEntry(id any, size int64, sum []byte, r io.Reader) error {
+	idBuf, err := json.Marshal(id)
+	if err != nil {
+		return fmt.Errorf("cannot encode id: %w", err)
+	}
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     entryPrefix + strconv.FormatInt(w.count, 10),
+		Size:     size,
+		Mode:     0600,
+		ModTime:  time.Unix(0, 0),
+		Format:   tar.FormatPAX,
+		PAXRecords: map[string]string{
+			paxID:     string(idBuf),
+			paxSHA256: hex.EncodeToString(sum),
+		},
+	}
+
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w.tw, r); err != nil {
+		return err
+	}
+
+	w.count++
+	w.digest.Write(sum)
+
+	return nil
+}
+
+// close writes the manifest and the tar footer.
+func (w *writer) close() error {
+	if err := w.writeJSON(manifestName, Manifest{Count: w.count, SHA256: hex.EncodeToString(w.digest.Sum(nil))}); err != nil {
+		return err
+	}
+
+	return w.tw.Close()
+}
+
+func (w *writer) writeJSON(name string, v any) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(len(buf)),
+		Mode:     0600,
+		ModTime:  time.Unix(0, 0),
+		Format:   tar.FormatPAX,
+	}); err != nil {
+		return err
+	}
+
+	_, err = w.tw.Write(buf)
+	return err
+}
+
+// reader reads and verifies the common structure of an archive.
+type reader struct {
+	tr     *tar.Reader
+	header Header
+	count  int64
+	digest hash.Hash
+}
+
+// entry is a single entity or blob within an archive. The content must be read completely, to verify the checksum.
+type entry struct {
+	id     []byte
+	sum    []byte
+	size   int64
+	hasher hash.Hash
+	r      io.Reader
+}
+
+func (e *entry) Read(p []byte) (int, error) {
+	return e.r.Read(p)
+}
+
+// verify returns a ChecksumError, if the content which has been read does not match.
+func (e *entry) verify() error {
+	if !bytes.Equal(e.hasher.Sum(nil), e.sum) {
+		return fmt.Errorf("%w: entry %s", ChecksumError, e.id)
+	}
+
+	return nil
+}
+
+func newReader(r io.Reader, typeName, codec string) (*reader, error) {
+	ar := &reader{tr: tar.NewReader(r), digest: sha256.New()}
+	hdr, err := ar.tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read archive header: %w", err)
+	}
+
+	if hdr.Name != headerName {
+		return nil, fmt.Errorf("invalid archive: expected %s but got %s", headerName, hdr.Name)
+	}
+
+	if err := json.NewDecoder(ar.tr).Decode(&ar.header); err != nil {
+		return nil, fmt.Errorf("cannot decode archive header: %w", err)
+	}
+
+	if ar.header.Format != format || ar.header.Version != version {
+		return nil, fmt.Errorf("unsupported archive format %s version %d", ar.header.Format, ar.header.Version)
+	}
+
+	if ar.header.Type != typeName || ar.header.Codec != codec {
+		return nil, fmt.Errorf("archive contains %s (%s) but expected %s (%s)", ar.header.Type, ar.header.Codec, typeName, codec)
+	}
+
+	return ar, nil
+}
+
+// next returns the next entry or io.EOF after the manifest has been verified successfully.
+func (r *reader) next() (*entry, error) {
+	hdr, err := r.tr.Next()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("truncated archive: %w", io.ErrUnexpectedEOF)
+		}
+
+		return nil, err
+	}
+
+	if hdr.Name == manifestName {
+		var manifest Manifest
+		if err := json.NewDecoder(r.tr).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("cannot decode manifest: %w", err)
+		}
+
+		if manifest.Count != r.count || manifest.SHA256 != hex.EncodeToString(r.digest.Sum(nil)) {
+			return nil, fmt.Errorf("%w: manifest", ChecksumError)
+		}
+
+		return nil, io.EOF
+	}
+
+	sum, err := hex.DecodeString(hdr.PAXRecords[paxSHA256])
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("invalid checksum of entry %s", hdr.Name)
+	}
+
+	r.count++
+	r.digest.Write(sum)
+
+	h := sha256.New()
+
+	return &entry{
+		id:     []byte(hdr.PAXRecords[paxID]),
+		sum:    sum,
+		size:   hdr.Size,
+		hasher: h,
+		r:      io.TeeReader(r.tr, h),
+	}, nil
+}
@@ -0,0 +1,139 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/golangee/repository/fs"
+	"github.com/golangee/repository/internal/test"
+	"github.com/golangee/repository/mem"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	src := mem.NewRepository[*test.B, int]()
+	for i, e := range test.CreateTestSet3() {
+		if err := src.Save(e.ID, e.Entity); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := src.Save(e.ID+10, &test.B{ID: "other", Age: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Export[*test.B, int](ctx, src, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := fs.NewRepository[*test.B, int](fs.Dir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a truncated archive fails, but can be resumed
+	truncated := buf.Bytes()[:buf.Len()/2]
+	if err := Import[*test.B, int](ctx, bytes.NewReader(truncated), dst); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if err := Import[*test.B, int](ctx, bytes.NewReader(buf.Bytes()), dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := src.FindAll(func(id int, expected *test.B) error {
+		actual, err := dst.FindByID(id)
+		if err != nil {
+			return err
+		}
+
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("expected %v but got %v", expected, actual)
+		}
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// wrong type
+	if err := Import[string, int](ctx, bytes.NewReader(buf.Bytes()), mem.NewRepository[string, int]()); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// tampered content
+	tampered := bytes.Replace(buf.Bytes(), []byte("Leuchtturm"), []byte("Leuchtfeuer"), 1)
+	if err := Import[*test.B, int](ctx, bytes.NewReader(tampered), mem.NewRepository[*test.B, int]()); !errors.Is(err, ChecksumError) {
+		t.Fatalf("expected checksum error but got %v", err)
+	}
+}
+
+func TestExportImportBlobs(t *testing.T) {
+	ctx := context.Background()
+	src, err := fs.NewBlobRepository[string](fs.Dir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blobs := map[string][]byte{
+		"a":     []byte("hello"),
+		"b/c":   bytes.Repeat([]byte{1, 2, 3}, 10000),
+		"empty": {},
+	}
+
+	for id, data := range blobs {
+		w, err := src.Write(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ExportBlobs[string](ctx, src, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := fs.NewBlobRepository[string](fs.Dir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ { // second import skips everything
+		if err := ImportBlobs[string](ctx, bytes.NewReader(buf.Bytes()), dst); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, _ := dst.Count(ctx); n != int64(len(blobs)) {
+		t.Fatalf("expected %v blobs but got %v", len(blobs), n)
+	}
+
+	for id, data := range blobs {
+		r, err := dst.Read(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := io.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(actual, data) {
+			t.Fatalf("blob %s differs", id)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/iter"
+	"io"
+	"os"
+)
+
+const blobType = "blob"
+
+// ExportBlobs streams all blobs of the repository into a new archive. Because tar requires the size of each entry
+// in advance, each blob is spooled into a temporary file first, so blobs are never held in memory.
+func ExportBlobs[ID comparable](ctx context.Context, repo repository.BlobRepository[ID], w io.Writer) error {
+	aw, err := newWriter(w, Header{Type: blobType, Codec: CodecRaw})
+	if err != nil {
+		return err
+	}
+
+	ids, err := repo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := iter.Walk(ids, func(id ID) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		return exportBlob(ctx, repo, aw, id)
+	}); err != nil {
+		return err
+	}
+
+	return aw.close()
+}
+
+func exportBlob[ID comparable](ctx context.Context, repo repository.BlobRepository[ID], aw *writer, id ID) error {
+	reader, err := repo.Read(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+
+	tmp, err := os.CreateTemp("", "golangee-archive-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), reader)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return aw.writeEntry(id, size, h.Sum(nil), tmp)
+}
+
+// ImportBlobs writes all blobs of the archive into the repository. A blob is only committed, if its content
+// matches its checksum. Blobs which already exist with identical content are skipped, so an interrupted import
+// can be resumed by just importing the same archive again.
+func ImportBlobs[ID comparable](ctx context.Context, r io.Reader, repo repository.BlobRepository[ID]) error {
+	ar, err := newReader(r, blobType, CodecRaw)
+	if err != nil {
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		e, err := ar.next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var id ID
+		if err := json.Unmarshal(e.id, &id); err != nil {
+			return fmt.Errorf("cannot decode id %s: %w", e.id, err)
+		}
+
+		if sum, err := blobChecksum(ctx, repo, id); err == nil && bytes.Equal(sum, e.sum) {
+			continue // already imported
+		}
+
+		if err := importBlob(ctx, repo, id, e); err != nil {
+			return err
+		}
+	}
+}
+
+func importBlob[ID comparable](ctx context.Context, repo repository.BlobRepository[ID], id ID, e *entry) error {
+	// cancelling the context is the contract to discard a write
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w, err := repo.Write(wctx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, e); err != nil {
+		cancel()
+		_ = w.Close()
+		return err
+	}
+
+	if err := e.verify(); err != nil {
+		cancel()
+		_ = w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+func blobChecksum[ID comparable](ctx context.Context, repo repository.BlobRepository[ID], id ID) ([]byte, error) {
+	reader, err := repo.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,104 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/internal/reflect"
+	"io"
+)
+
+// Export streams all entities of the repository as json into a new archive.
+func Export[T any, ID comparable](ctx context.Context, repo repository.CrudRepository[T, ID], w io.Writer) error {
+	aw, err := newWriter(w, Header{Type: reflect.TypeName[T](), Codec: CodecJSON})
+	if err != nil {
+		return err
+	}
+
+	if err := repo.FindAll(func(id ID, entity T) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		buf, err := json.Marshal(entity)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(buf)
+
+		return aw.writeEntry(id, int64(len(buf)), sum[:], bytes.NewReader(buf))
+	}); err != nil {
+		return err
+	}
+
+	return aw.close()
+}
+
+// Import saves all entities of the archive into the repository. Each entity is verified against its checksum
+// before it is saved. Entities which already exist with identical content are skipped, so an interrupted import
+// can be resumed by just importing the same archive again. The manifest is verified at the end, thus
+// entities of a corrupted archive may have been saved before a ChecksumError is returned.
+func Import[T any, ID comparable](ctx context.Context, r io.Reader, repo repository.CrudRepository[T, ID]) error {
+	ar, err := newReader(r, reflect.TypeName[T](), CodecJSON)
+	if err != nil {
+		return err
+	}
+
+	factory, isPtr := reflect.Constructor[T]()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		e, err := ar.next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		buf, err := io.ReadAll(e)
+		if err != nil {
+			return err
+		}
+
+		if err := e.verify(); err != nil {
+			return err
+		}
+
+		var id ID
+		if err := json.Unmarshal(e.id, &id); err != nil {
+			return fmt.Errorf("cannot decode id %s: %w", e.id, err)
+		}
+
+		if existing, err := repo.FindByID(id); err == nil {
+			if current, err := json.Marshal(existing); err == nil {
+				if sum := sha256.Sum256(current); bytes.Equal(sum[:], e.sum) {
+					continue // already imported
+				}
+			}
+		}
+
+		entity := factory()
+		if isPtr {
+			err = json.Unmarshal(buf, entity)
+		} else {
+			err = json.Unmarshal(buf, &entity)
+		}
+
+		if err != nil {
+			return fmt.Errorf("cannot decode entity %s: %w", e.id, err)
+		}
+
+		if err := repo.Save(id, entity); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package audit provides decorators, which record every mutation of a CrudRepository or BlobRepository into an
+// append-only Sink.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/internal/lock"
+	"hash"
+	"time"
+)
+
+// Op denotes the kind of mutation.
+type Op string
+
+const (
+	OpSave      Op = "save"       // OpSave denotes a saved entity or a written blob.
+	OpDelete    Op = "delete"     // OpDelete denotes a deleted entity or blob.
+	OpDeleteAll Op = "delete_all" // OpDeleteAll denotes a cleared repository. The record has neither an ID nor digests.
+)
+
+// Record describes a single mutation. Digests are hex encoded sha256 sums of the json encoded entity or the raw
+// blob data. An empty digest denotes a non-existing entity or blob.
+type Record struct {
+	Time   time.Time       `json:"time"`
+	Op     Op              `json:"op"`
+	ID     json.RawMessage `json:"id,omitempty"` // ID is the json encoded identifier.
+	Actor  string          `json:"actor,omitempty"`
+	Before string          `json:"before,omitempty"`
+	After  string          `json:"after,omitempty"`
+	Error  string          `json:"error,omitempty"` // Error is the message of a failed mutation.
+}
+
+// A Sink stores records in order and must be safe for concurrent use. An error causes the audited operation to
+// fail, however the mutation itself has already been applied.
+type Sink interface {
+	Append(rec Record) error
+}
+
+type actorKey struct{}
+
+// WithActor returns a context which identifies the actor of all mutations performed using it.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor of the context or the empty string.
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// record creates a record for the operation performed by the actor of ctx.
+func record(ctx context.Context, now func() time.Time, op Op, id any, opErr error) (Record, error) {
+	rec := Record{Time: now(), Op: op, Actor: Actor(ctx)}
+	if op != OpDeleteAll {
+		buf, err := json.Marshal(id)
+		if err != nil {
+			return rec, err
+		}
+
+		rec.ID = buf
+	}
+
+	if opErr != nil {
+		rec.Error = opErr.Error()
+	}
+
+	return rec, nil
+}
+
+// appendRecord appends the record and returns the error of the operation or otherwise of the sink.
+func appendRecord(sink Sink, rec Record, opErr error) error {
+	if err := sink.Append(rec); err != nil && opErr == nil {
+		return err
+	}
+
+	return opErr
+}
+
+// lockID acquires the shared lock of all, which excludes a DeleteAll, and the exclusive lock of the id.
+func lockID[ID comparable](ctx context.Context, all *lock.Table[struct{}], ids *lock.Table[ID], id ID) (repository.Unlock, error) {
+	unlockAll, err := all.RLock(ctx, struct{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := ids.Lock(ctx, id)
+	if err != nil {
+		_ = unlockAll()
+		return nil, err
+	}
+
+	return func() error {
+		err := unlock()
+		if e := unlockAll(); err == nil {
+			err = e
+		}
+
+		return err
+	}, nil
+}
+
+func newDigest() hash.Hash {
+	return sha256.New()
+}
+
+func digest(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
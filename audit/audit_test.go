@@ -0,0 +1,267 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/fs"
+	"github.com/golangee/repository/internal/test"
+	"github.com/golangee/repository/mem"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRepository(t *testing.T) {
+	sink := &MemorySink{}
+	repo := NewRepository[*test.B, int](mem.NewRepository[*test.B, int](), sink)
+	test.Test[*test.B, int](t, test.CreateTestSet3(), repo)
+
+	alice := repo.WithContext(WithActor(context.Background(), "alice"))
+	sink.records = nil
+
+	if err := alice.Save(1, &test.B{ID: "one"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := alice.Save(1, &test.B{ID: "two"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := alice.DeleteByID(1); err != nil {
+		t.Fatal(err)
+	}
+
+	records := sink.Records()
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records but got %v", records)
+	}
+
+	for _, rec := range records {
+		if rec.Actor != "alice" || string(rec.ID) != "1" {
+			t.Fatalf("unexpected record %+v", rec)
+		}
+	}
+
+	if records[0].Before != "" || records[0].After == "" || records[1].Before != records[0].After ||
+		records[2].Before != records[1].After || records[2].After != "" {
+		t.Fatalf("digests are not chained: %+v", records)
+	}
+}
+
+func TestRepository_SaveAll(t *testing.T) {
+	sink := &MemorySink{}
+	repo := NewRepository[int, int](mem.NewRepository[int, int](), sink)
+
+	i := 0
+	failure := errors.New("producer failed")
+	err := repo.SaveAll(func() (int, int, error) {
+		i++
+		if i > 3 {
+			return 0, 0, failure
+		}
+
+		// the previous entity is already recorded, so nothing is buffered
+		if n := len(sink.Records()); n != i-1 {
+			t.Fatalf("expected %v records but got %v", i-1, n)
+		}
+
+		return i, i, nil
+	})
+
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected producer failure but got %v", err)
+	}
+}
+
+func TestBlobRepository(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewFileSink(fs.Dir(dir), "audit.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blobs, err := fs.NewBlobRepository[string](fs.Dir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithActor(context.Background(), "bob")
+	repo := NewBlobRepository[string](blobs, sink)
+
+	w, err := repo.Write(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(w, "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	w, err = repo.Write(cancelled, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if err := w.Close(); err == nil {
+		t.Fatal("expected cancelled write")
+	}
+
+	if err := repo.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.DeleteAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	var records []Record
+	if err := Decode(file, func(rec Record) error {
+		records = append(records, rec)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// sha256 of hello
+	const hello = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if len(records) != 4 || records[0].After != hello || records[1].Error == "" || records[1].After != hello ||
+		records[2].Before != hello || records[3].Op != OpDeleteAll || records[3].Actor != "bob" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+}
+
+// assertChained checks, that each before digest equals the after digest of the previous record.
+func assertChained(t *testing.T, records []Record) {
+	t.Helper()
+	after := ""
+	for i, rec := range records {
+		if rec.Op == OpDeleteAll {
+			after = ""
+			continue
+		}
+
+		if rec.Before != after {
+			t.Fatalf("record %d is not chained: %+v", i, rec)
+		}
+
+		after = rec.After
+	}
+}
+
+// slowReads widens the window between reading the before digest and the mutation.
+type slowReads[T any, ID comparable] struct {
+	repository.CrudRepository[T, ID]
+}
+
+func (r slowReads[T, ID]) FindByID(id ID) (T, error) {
+	entity, err := r.CrudRepository.FindByID(id)
+	time.Sleep(time.Millisecond)
+
+	return entity, err
+}
+
+func TestRepository_Concurrent(t *testing.T) {
+	sink := &MemorySink{}
+	repo := NewRepository[int, int](slowReads[int, int]{mem.NewRepository[int, int]()}, sink)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if j%5 == 0 {
+					_ = repo.DeleteByID(1)
+				} else {
+					_ = repo.Save(1, i*100+j)
+				}
+			}
+		}()
+	}
+
+	// clear concurrently to the mutations of other callers
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 10; j++ {
+			_ = repo.DeleteAll()
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	wg.Wait()
+	assertChained(t, sink.Records())
+}
+
+func TestBlobRepository_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	sink := &MemorySink{}
+	repo := NewBlobRepository[string](must(fs.NewBlobRepository[string](fs.Dir(t.TempDir()))), sink)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if j%5 == 0 {
+					_ = repo.Delete(ctx, "a")
+					continue
+				}
+
+				w, err := repo.Write(ctx, "a")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				_, _ = w.Write([]byte(strconv.Itoa(i*100 + j)))
+				_ = w.Close()
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 10; j++ {
+			_ = repo.DeleteAll(ctx)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	wg.Wait()
+	assertChained(t, sink.Records())
+}
+
+func must[T any](t T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
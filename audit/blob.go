@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/internal/lock"
+	"github.com/golangee/repository/iter"
+	"hash"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// BlobRepository records all mutations of the backend. The actor is taken from the context of each call.
+// Reads are not recorded. Calculating the digest of the previous blob requires to read it entirely.
+// Mutations of the same ID are serialized, a writer holds its lock until it is closed. DeleteAll waits for pending
+// mutations, including open writers, and blocks new ones. Hence, a caller must not start a mutation while holding
+// an open writer.
+type BlobRepository[ID comparable] struct {
+	backend repository.BlobRepository[ID]
+	sink    Sink
+	opts    options
+	ids     lock.Table[ID]
+	all     lock.Table[struct{}] // all is held shared by mutations of an ID and exclusively by DeleteAll
+}
+
+// NewBlobRepository creates an auditing decorator for the given backend.
+func NewBlobRepository[ID comparable](backend repository.BlobRepository[ID], sink Sink, opts ...Option) *BlobRepository[ID] {
+	return &BlobRepository[ID]{
+		backend: backend,
+		sink:    sink,
+		opts:    newOptions(opts),
+	}
+}
+
+func (r *BlobRepository[ID]) Count(ctx context.Context) (int64, error) {
+	return r.backend.Count(ctx)
+}
+
+func (r *BlobRepository[ID]) Delete(ctx context.Context, id ID) error {
+	unlock, err := lockID(ctx, &r.all, &r.ids, id)
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	before, err := r.digest(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	opErr := r.backend.Delete(ctx, id)
+	rec, err := record(ctx, r.opts.now, OpDelete, id, opErr)
+	if err != nil {
+		return err
+	}
+
+	rec.Before = before
+	if opErr != nil {
+		rec.After = before
+	}
+
+	return appendRecord(r.sink, rec, opErr)
+}
+
+func (r *BlobRepository[ID]) DeleteAll(ctx context.Context) error {
+	unlock, err := r.all.Lock(ctx, struct{}{})
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	opErr := r.backend.DeleteAll(ctx)
+	rec, err := record(ctx, r.opts.now, OpDeleteAll, nil, opErr)
+	if err != nil {
+		return err
+	}
+
+	return appendRecord(r.sink, rec, opErr)
+}
+
+// Write records the mutation when the returned writer is closed. The digest of the new blob is calculated
+// while streaming. A write which is discarded by cancelling the context is recorded as failed.
+func (r *BlobRepository[ID]) Write(ctx context.Context, id ID) (io.WriteCloser, error) {
+	unlock, err := lockID(ctx, &r.all, &r.ids, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := r.digest(ctx, id)
+	if err != nil {
+		_ = unlock()
+		return nil, err
+	}
+
+	w, err := r.backend.Write(ctx, id)
+	if err != nil {
+		_ = unlock()
+		return nil, err
+	}
+
+	return &writer[ID]{ctx: ctx, repo: r, id: id, before: before, w: w, hash: newDigest(), unlock: unlock}, nil
+}
+
+func (r *BlobRepository[ID]) Read(ctx context.Context, id ID) (io.ReadCloser, error) {
+	return r.backend.Read(ctx, id)
+}
+
+func (r *BlobRepository[ID]) FindAll(ctx context.Context) (iter.Iterator[ID], error) {
+	return r.backend.FindAll(ctx)
+}
+
+// digest returns the digest of the current blob or the empty string, if it does not exist.
+func (r *BlobRepository[ID]) digest(ctx context.Context, id ID) (string, error) {
+	reader, err := r.backend.Read(ctx, id)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.As(err, &repository.EntityNotFoundError{}) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	defer reader.Close()
+
+	h := newDigest()
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", err
+	}
+
+	return digest(h), nil
+}
+
+type writer[ID comparable] struct {
+	ctx    context.Context
+	repo   *BlobRepository[ID]
+	id     ID
+	before string
+	w      io.WriteCloser
+	hash   hash.Hash
+	unlock repository.Unlock // unlock releases the locks of id, which are held from the before digest until Close
+	once   sync.Once
+	err    error
+}
+
+func (w *writer[ID]) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.hash.Write(p[:n])
+
+	return n, err
+}
+
+func (w *writer[ID]) Close() error {
+	w.once.Do(func() {
+		defer w.unlock()
+
+		opErr := w.w.Close()
+		rec, err := record(w.ctx, w.repo.opts.now, OpSave, w.id, opErr)
+		if err != nil {
+			w.err = err
+			return
+		}
+
+		rec.Before = w.before
+		if opErr != nil {
+			rec.After = w.before
+		} else {
+			rec.After = digest(w.hash)
+		}
+
+		w.err = appendRecord(w.repo.sink, rec, opErr)
+	})
+
+	return w.err
+}
@@ -0,0 +1,185 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/internal/lock"
+	"io"
+)
+
+// Repository records all mutations of the backend. Because CrudRepository methods do not accept a context,
+// the actor is taken from the context bound by WithContext. Reads are not recorded.
+// Mutations of the same ID are serialized, so that the before digest of a record always equals the after digest
+// of the previous record, as long as the backend is not modified by others. DeleteAll waits for pending mutations
+// and blocks new ones, so that no mutation is recorded before a DeleteAll, which has been applied after it.
+type Repository[T any, ID comparable] struct {
+	backend repository.CrudRepository[T, ID]
+	sink    Sink
+	opts    options
+	ctx     context.Context
+	ids     *lock.Table[ID]       // ids is shared by all copies of WithContext
+	all     *lock.Table[struct{}] // all is held shared by mutations of an ID and exclusively by DeleteAll
+}
+
+// NewRepository creates an auditing decorator for the given backend, which is bound to the background context.
+func NewRepository[T any, ID comparable](backend repository.CrudRepository[T, ID], sink Sink, opts ...Option) *Repository[T, ID] {
+	return &Repository[T, ID]{
+		backend: backend,
+		sink:    sink,
+		opts:    newOptions(opts),
+		ctx:     context.Background(),
+		ids:     &lock.Table[ID]{},
+		all:     &lock.Table[struct{}]{},
+	}
+}
+
+// WithContext returns a shallow copy which records the actor of the given context.
+func (r *Repository[T, ID]) WithContext(ctx context.Context) *Repository[T, ID] {
+	c := *r
+	c.ctx = ctx
+
+	return &c
+}
+
+func (r *Repository[T, ID]) Count() (int64, error) {
+	return r.backend.Count()
+}
+
+func (r *Repository[T, ID]) DeleteByID(id ID) error {
+	unlock, err := r.lock(id)
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	before, err := r.digest(id)
+	if err != nil {
+		return err
+	}
+
+	opErr := r.backend.DeleteByID(id)
+	rec, err := record(r.ctx, r.opts.now, OpDelete, id, opErr)
+	if err != nil {
+		return err
+	}
+
+	rec.Before = before
+	if opErr != nil {
+		rec.After = before
+	}
+
+	return appendRecord(r.sink, rec, opErr)
+}
+
+func (r *Repository[T, ID]) DeleteAll() error {
+	unlock, err := r.all.Lock(context.Background(), struct{}{})
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	opErr := r.backend.DeleteAll()
+	rec, err := record(r.ctx, r.opts.now, OpDeleteAll, nil, opErr)
+	if err != nil {
+		return err
+	}
+
+	return appendRecord(r.sink, rec, opErr)
+}
+
+func (r *Repository[T, ID]) Save(id ID, entity T) error {
+	return r.save(id, entity)
+}
+
+// SaveAll saves and records each entity individually, so that the producer is streamed without buffering.
+func (r *Repository[T, ID]) SaveAll(f func() (ID, T, error)) error {
+	for {
+		id, entity, err := f()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := r.save(id, entity); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Repository[T, ID]) FindByID(id ID) (T, error) {
+	return r.backend.FindByID(id)
+}
+
+func (r *Repository[T, ID]) FindAll(f func(id ID, entity T) error) error {
+	return r.backend.FindAll(f)
+}
+
+func (r *Repository[T, ID]) save(id ID, entity T) error {
+	after, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := r.lock(id)
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	before, err := r.digest(id)
+	if err != nil {
+		return err
+	}
+
+	opErr := r.backend.Save(id, entity)
+	rec, err := record(r.ctx, r.opts.now, OpSave, id, opErr)
+	if err != nil {
+		return err
+	}
+
+	rec.Before = before
+	if opErr != nil {
+		rec.After = before
+	} else {
+		h := newDigest()
+		h.Write(after)
+		rec.After = digest(h)
+	}
+
+	return appendRecord(r.sink, rec, opErr)
+}
+
+// lock serializes the mutations of the id and excludes a concurrent DeleteAll.
+func (r *Repository[T, ID]) lock(id ID) (repository.Unlock, error) {
+	return lockID(context.Background(), r.all, r.ids, id)
+}
+
+// digest returns the digest of the current entity or the empty string, if it does not exist.
+func (r *Repository[T, ID]) digest(id ID) (string, error) {
+	entity, err := r.backend.FindByID(id)
+	if err != nil {
+		if errors.As(err, &repository.EntityNotFoundError{}) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	buf, err := json.Marshal(entity)
+	if err != nil {
+		return "", err
+	}
+
+	h := newDigest()
+	h.Write(buf)
+
+	return digest(h), nil
+}
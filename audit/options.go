@@ -0,0 +1,29 @@
+package audit
+
+import "time"
+
+// Option configures a decorator.
+type Option func(*options)
+
+type options struct {
+	now func() time.Time
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		now: time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithClock replaces the wall clock, which is used to timestamp the records.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
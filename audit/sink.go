@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	rfs "github.com/golangee/repository/fs"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// MemorySink keeps all records in memory, which is mostly useful for testing.
+type MemorySink struct {
+	mutex   sync.Mutex
+	records []Record
+}
+
+// Append implements Sink.
+func (s *MemorySink) Append(rec Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.records = append(s.records, rec)
+
+	return nil
+}
+
+// Records returns a copy of all appended records.
+func (s *MemorySink) Records() []Record {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Record(nil), s.records...)
+}
+
+// FileSink appends json encoded records line by line to a file, which is synced after each record.
+type FileSink struct {
+	mutex sync.Mutex
+	file  rfs.WriteableFile
+}
+
+// NewFileSink opens or creates the named file for appending. The filesystem must support OpenFileFS.
+func NewFileSink(fsys fs.FS, name string) (*FileSink, error) {
+	file, err := rfs.OpenFile(fsys, name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	w, ok := file.(rfs.WriteableFile)
+	if !ok {
+		_ = file.Close()
+		return nil, rfs.WriteableFileNotSupported
+	}
+
+	return &FileSink{file: w}, nil
+}
+
+// Append implements Sink.
+func (s *FileSink) Append(rec Record) error {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return fs.ErrClosed
+	}
+
+	if _, err := s.file.Write(append(buf, '\n')); err != nil {
+		return fmt.Errorf("cannot append audit record: %w", err)
+	}
+
+	if syncer, ok := s.file.(rfs.SyncableFile); ok {
+		if err := syncer.Sync(); err != nil {
+			return fmt.Errorf("fsync failed on audit log: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the file. Any further Append fails.
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+
+	return err
+}
+
+// Decode reads all records written by a FileSink and invokes f for each record in order. A torn last line, e.g.
+// caused by a crash, is ignored.
+func Decode(r io.Reader, f func(rec Record) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil // either done or a torn line without newline
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("invalid audit record: %w", err)
+		}
+
+		if err := f(rec); err != nil {
+			return err
+		}
+	}
+}
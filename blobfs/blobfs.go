@@ -0,0 +1,302 @@
+// Package blobfs provides an adapter, which exposes a BlobRepository as a read-only io/fs.FS, e.g. to be
+// served by http.FileServer or parsed by template.ParseFS.
+package blobfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/iter"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS is a read-only view of a BlobRepository. Each ID is a file path and directories are synthesized from the
+// / separated ID segments. IDs which are not valid paths according to fs.ValidPath are ignored. If an ID denotes
+// both a file and a directory, the file takes precedence. Opening a directory lists all IDs of the repository.
+type FS struct {
+	ctx  context.Context
+	repo repository.BlobRepository[string]
+}
+
+// New creates an adapter, which performs all repository calls with the given context.
+func New(ctx context.Context, repo repository.BlobRepository[string]) *FS {
+	return &FS{ctx: ctx, repo: repo}
+}
+
+// Open implements fs.FS.
+func (f *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name != "." {
+		rc, err := f.repo.Read(f.ctx, name)
+		if err == nil {
+			return &file{fsys: f, name: name, rc: rc, size: -1}, nil
+		}
+
+		if !notFound(err) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+
+	entries, err := f.readDir("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dir{info: dirInfo(name), entries: entries}, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name != "." {
+		if _, err := f.size(name); err == nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+		} else if !notFound(err) {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+	}
+
+	return f.readDir("readdir", name)
+}
+
+// Stat implements fs.StatFS.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name != "." {
+		size, err := f.size(name)
+		if err == nil {
+			return fileInfo{name: path.Base(name), size: size}, nil
+		}
+
+		if !notFound(err) {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+		}
+	}
+
+	if _, err := f.readDir("stat", name); err != nil {
+		return nil, err
+	}
+
+	return dirInfo(name), nil
+}
+
+// size returns the size of the blob, preferably without reading it.
+func (f *FS) size(name string) (int64, error) {
+	if sizer, ok := f.repo.(interface {
+		Size(ctx context.Context, id string) (int64, error)
+	}); ok {
+		return sizer.Size(f.ctx, name)
+	}
+
+	rc, err := f.repo.Read(f.ctx, name)
+	if err != nil {
+		return 0, err
+	}
+
+	defer rc.Close()
+
+	return io.Copy(io.Discard, rc)
+}
+
+// readDir synthesizes the sorted entries of the named directory.
+func (f *FS) readDir(op, name string) ([]fs.DirEntry, error) {
+	it, err := f.repo.FindAll(f.ctx)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+
+	files := map[string]bool{}
+	dirs := map[string]bool{}
+	found := name == "."
+	err = iter.Walk(it, func(id string) error {
+		if !fs.ValidPath(id) || id == "." || !strings.HasPrefix(id, prefix) {
+			return nil
+		}
+
+		found = true
+		child := strings.TrimPrefix(id, prefix)
+		if i := strings.IndexByte(child, '/'); i >= 0 {
+			dirs[child[:i]] = true
+		} else {
+			files[child] = true
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	if !found {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(files)+len(dirs))
+	for child := range files {
+		entries = append(entries, &entry{fsys: f, name: path.Join(name, child)})
+	}
+
+	for child := range dirs {
+		if !files[child] {
+			entries = append(entries, fs.FileInfoToDirEntry(dirInfo(child)))
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+func notFound(err error) bool {
+	var nf interface{ NotFound() bool }
+	return (errors.As(err, &nf) && nf.NotFound()) || errors.Is(err, fs.ErrNotExist)
+}
+
+type fileInfo struct {
+	name  string
+	size  int64
+	isDir bool
+}
+
+func dirInfo(name string) fileInfo {
+	return fileInfo{name: path.Base(name), isDir: true}
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) ModTime() time.Time { return time.Time{} }
+func (i fileInfo) IsDir() bool        { return i.isDir }
+func (i fileInfo) Sys() any           { return nil }
+
+func (i fileInfo) Mode() fs.FileMode {
+	if i.isDir {
+		return fs.ModeDir | 0555
+	}
+
+	return 0444
+}
+
+// entry is a file entry, which determines its size lazily.
+type entry struct {
+	fsys *FS
+	name string
+}
+
+func (e *entry) Name() string      { return path.Base(e.name) }
+func (e *entry) IsDir() bool       { return false }
+func (e *entry) Type() fs.FileMode { return 0 }
+
+func (e *entry) Info() (fs.FileInfo, error) {
+	size, err := e.fsys.size(e.name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: e.name, Err: err}
+	}
+
+	return fileInfo{name: e.Name(), size: size}, nil
+}
+
+// file streams a blob. If the size is requested and the repository cannot tell it, the remaining blob is buffered.
+type file struct {
+	fsys *FS
+	name string
+	rc   io.ReadCloser
+	read int64
+	size int64 // size is -1, if not yet known
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.rc.Read(p)
+	f.read += int64(n)
+
+	return n, err
+}
+
+func (f *file) Close() error {
+	return f.rc.Close()
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	if f.size < 0 {
+		size, err := f.fsys.size(f.name)
+		if err != nil && notFound(err) {
+			// deleted concurrently, but we can still tell the size of our stream
+			rest, err := io.ReadAll(f.rc)
+			if err != nil {
+				return nil, &fs.PathError{Op: "stat", Path: f.name, Err: err}
+			}
+
+			_ = f.rc.Close()
+			f.rc = io.NopCloser(bytes.NewReader(rest))
+			size = f.read + int64(len(rest))
+		} else if err != nil {
+			return nil, &fs.PathError{Op: "stat", Path: f.name, Err: err}
+		}
+
+		f.size = size
+	}
+
+	return fileInfo{name: path.Base(f.name), size: f.size}, nil
+}
+
+// dir is an open directory.
+type dir struct {
+	info    fileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(rest) {
+		n = len(rest)
+	}
+
+	d.offset += n
+
+	return rest[:n], nil
+}
@@ -0,0 +1,59 @@
+package blobfs
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository"
+	rfs "github.com/golangee/repository/fs"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	ctx := context.Background()
+	blobs, err := rfs.NewBlobRepository[string](rfs.Dir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt", "dir/sub/d.txt"} {
+		w, err := blobs.Write(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := io.WriteString(w, "content of "+name); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the fs repository keeps its fanout directories, which are empty and therefore invisible
+	if err := fstest.TestFS(New(ctx, blobs), "a.txt", "dir/b.txt", "dir/sub/c.txt", "dir/sub/d.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// without a Size method, the blobs are read to determine their size
+	if err := fstest.TestFS(New(ctx, struct{ repository.BlobRepository[string] }{blobs}), "dir/sub/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := New(ctx, blobs)
+	if _, err := fsys.Open("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist but got %v", err)
+	}
+
+	if _, err := fsys.Open("../a.txt"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("expected invalid but got %v", err)
+	}
+
+	buf, err := fs.ReadFile(fsys, "dir/b.txt")
+	if err != nil || string(buf) != "content of dir/b.txt" {
+		t.Fatalf("unexpected content %q: %v", buf, err)
+	}
+}
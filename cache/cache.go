@@ -0,0 +1,275 @@
+// Package cache provides a caching decorator, which puts a mem.Repository in front of any CrudRepository.
+package cache
+
+import (
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/mem"
+	"io"
+	"sync"
+	"time"
+)
+
+// Repository is a read-through cache for any CrudRepository. Entities are loaded from the backend on a cache miss
+// and saved either write-through or write-behind. Deletes invalidate the cache synchronously.
+// The cache never serves data which has been deleted or overwritten through this instance, but it is not
+// aware of modifications applied to the backend directly.
+type Repository[T any, ID comparable] struct {
+	backend repository.CrudRepository[T, ID]
+	cache   *mem.Repository[T, ID]
+	pending *mem.Repository[T, ID] // pending contains the not yet flushed entities of WriteBehind
+	mode    Mode
+
+	// writers are holding the read lock and are serialized per ID by ids. DeleteAll holds the write lock.
+	writers sync.RWMutex
+	ids     keyMutex[ID]
+
+	// mutex guards epoch and makes cache fills atomic with respect to the invalidation by writers.
+	mutex sync.Mutex
+	epoch uint64
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// NewRepository creates a caching decorator for the given backend. When using WriteBehind, Close
+// must be called to flush all pending entities.
+func NewRepository[T any, ID comparable](backend repository.CrudRepository[T, ID], opts ...Option) *Repository[T, ID] {
+	o := newOptions(opts)
+	r := &Repository[T, ID]{
+		backend: backend,
+		cache:   mem.NewRepository[T, ID](o.cache...),
+		pending: mem.NewRepository[T, ID](),
+		mode:    o.mode,
+		stop:    make(chan struct{}),
+	}
+
+	if r.mode == WriteBehind {
+		r.done.Add(1)
+		go r.flusher(o.flushInterval)
+	}
+
+	return r
+}
+
+func (r *Repository[T, ID]) Count() (int64, error) {
+	if err := r.Flush(); err != nil {
+		return 0, err
+	}
+
+	return r.backend.Count()
+}
+
+func (r *Repository[T, ID]) DeleteByID(id ID) error {
+	r.writers.RLock()
+	defer r.writers.RUnlock()
+
+	r.ids.lock(id)
+	defer r.ids.unlock(id)
+
+	if err := r.pending.DeleteByID(id); err != nil {
+		return err
+	}
+
+	// invalidate before and after, so that a failing backend never leaves a stale entry
+	r.invalidate(func() { _ = r.cache.DeleteByID(id) })
+	err := r.backend.DeleteByID(id)
+	r.invalidate(func() { _ = r.cache.DeleteByID(id) })
+
+	return err
+}
+
+func (r *Repository[T, ID]) DeleteAll() error {
+	r.writers.Lock()
+	defer r.writers.Unlock()
+
+	if err := r.pending.DeleteAll(); err != nil {
+		return err
+	}
+
+	r.invalidate(func() { _ = r.cache.DeleteAll() })
+	err := r.backend.DeleteAll()
+	r.invalidate(func() { _ = r.cache.DeleteAll() })
+
+	return err
+}
+
+func (r *Repository[T, ID]) Save(id ID, entity T) error {
+	r.writers.RLock()
+	defer r.writers.RUnlock()
+
+	return r.save(id, entity)
+}
+
+// SaveAll saves each entity individually into the cache and backend.
+func (r *Repository[T, ID]) SaveAll(f func() (ID, T, error)) error {
+	r.writers.RLock()
+	defer r.writers.RUnlock()
+
+	for {
+		id, entity, err := f()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := r.save(id, entity); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Repository[T, ID]) FindByID(id ID) (T, error) {
+	if entity, err := r.cache.FindByID(id); err == nil {
+		return entity, nil
+	}
+
+	r.mutex.Lock()
+	epoch := r.epoch
+	r.mutex.Unlock()
+
+	if entity, err := r.pending.FindByID(id); err == nil {
+		return entity, nil
+	}
+
+	entity, err := r.backend.FindByID(id)
+	if err != nil {
+		return entity, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// only populate, if no writer has touched the cache in the meantime, otherwise we may have loaded stale data
+	if r.epoch == epoch {
+		if err := r.cache.Save(id, entity); err != nil {
+			return entity, err
+		}
+	}
+
+	return entity, nil
+}
+
+// FindAll flushes all pending entities and delegates to the backend, without populating the cache.
+func (r *Repository[T, ID]) FindAll(f func(id ID, entity T) error) error {
+	if err := r.Flush(); err != nil {
+		return err
+	}
+
+	return r.backend.FindAll(f)
+}
+
+// Stats returns the statistics of the cache.
+func (r *Repository[T, ID]) Stats() mem.Stats {
+	return r.cache.Stats()
+}
+
+// Flush writes all pending entities into the backend. This is a no-op for WriteThrough.
+func (r *Repository[T, ID]) Flush() error {
+	if r.mode != WriteBehind {
+		return nil
+	}
+
+	var ids []ID
+	if err := r.pending.FindAll(func(id ID, entity T) error {
+		ids = append(ids, id)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, id := range ids {
+		if err := r.flush(id); err != nil && firstErr == nil {
+			firstErr = err // try to flush as much as possible
+		}
+	}
+
+	return firstErr
+}
+
+// Close stops the background flusher, flushes all pending entities and releases the cache.
+func (r *Repository[T, ID]) Close() error {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+
+	r.done.Wait()
+
+	err := r.Flush()
+	_ = r.cache.Close()
+	_ = r.pending.Close()
+
+	return err
+}
+
+func (r *Repository[T, ID]) save(id ID, entity T) error {
+	r.ids.lock(id)
+	defer r.ids.unlock(id)
+
+	if r.mode == WriteBehind {
+		if err := r.pending.Save(id, entity); err != nil {
+			return err
+		}
+	} else {
+		r.invalidate(func() { _ = r.cache.DeleteByID(id) })
+		if err := r.backend.Save(id, entity); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	r.invalidate(func() { err = r.cache.Save(id, entity) })
+
+	return err
+}
+
+// flush writes a single pending entity into the backend, if not deleted in the meantime. Like any other writer,
+// it holds the read lock of writers, so that DeleteAll cannot interleave and a stale entity is never resurrected.
+func (r *Repository[T, ID]) flush(id ID) error {
+	r.writers.RLock()
+	defer r.writers.RUnlock()
+
+	r.ids.lock(id)
+	defer r.ids.unlock(id)
+
+	entity, err := r.pending.FindByID(id)
+	if err != nil {
+		return nil // deleted or flushed concurrently
+	}
+
+	if err := r.backend.Save(id, entity); err != nil {
+		return err
+	}
+
+	return r.pending.DeleteByID(id)
+}
+
+// invalidate applies the cache modification and prevents concurrent fills of loaded entities.
+func (r *Repository[T, ID]) invalidate(f func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.epoch++
+	f()
+}
+
+func (r *Repository[T, ID]) flusher(interval time.Duration) {
+	defer r.done.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			_ = r.Flush() // failed entities are retried next time
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"errors"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/internal/test"
+	"github.com/golangee/repository/mem"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRepository(t *testing.T) {
+	for _, mode := range []Mode{WriteThrough, WriteBehind} {
+		a := NewRepository[test.A, string](mem.NewRepository[test.A, string](), WithMode(mode))
+		test.Test[test.A, string](t, test.CreateTestSet1(), a)
+
+		a3 := NewRepository[*test.B, int](mem.NewRepository[*test.B, int](), WithMode(mode), WithCache(mem.WithMaxEntries(1)))
+		test.Test[*test.B, int](t, test.CreateTestSet3(), a3)
+
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := a3.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRepository_ReadThrough(t *testing.T) {
+	backend := mem.NewRepository[string, int]()
+	if err := backend.Save(1, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewRepository[string, int](backend)
+	defer repo.Close()
+
+	for i := 0; i < 3; i++ {
+		if v, err := repo.FindByID(1); err != nil || v != "a" {
+			t.Fatalf("expected a but got %v %v", v, err)
+		}
+	}
+
+	if stats := repo.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	if err := repo.DeleteByID(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.FindByID(1); !errors.As(err, &repository.EntityNotFoundError{}) {
+		t.Fatalf("expected not found but got %v", err)
+	}
+}
+
+func TestRepository_WriteBehind(t *testing.T) {
+	backend := mem.NewRepository[string, int]()
+	repo := NewRepository[string, int](backend, WithMode(WriteBehind))
+
+	if err := repo.Save(1, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := repo.FindByID(1); err != nil || v != "a" {
+		t.Fatalf("expected a but got %v %v", v, err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := backend.FindByID(1); err != nil || v != "a" {
+		t.Fatalf("expected flushed a but got %v %v", v, err)
+	}
+}
+
+func TestRepository_Concurrency(t *testing.T) {
+	backend := mem.NewRepository[string, int]()
+	repo := NewRepository[string, int](backend, WithCache(mem.WithMaxEntries(4)))
+	defer repo.Close()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				id := i % 8
+				switch (i + w) % 3 {
+				case 0:
+					_ = repo.Save(id, strconv.Itoa(i))
+				case 1:
+					_ = repo.DeleteByID(id)
+				default:
+					_, _ = repo.FindByID(id)
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	// after all writers are done, the cache must agree with the backend
+	for id := 0; id < 8; id++ {
+		expected, expectedErr := backend.FindByID(id)
+		actual, err := repo.FindByID(id)
+		if expected != actual || (expectedErr == nil) != (err == nil) {
+			t.Fatalf("cache is inconsistent for %v: expected %v %v but got %v %v", id, expected, expectedErr, actual, err)
+		}
+	}
+}
+
+// blockingBackend blocks each Save after it has been entered, until it is released.
+type blockingBackend[T any, ID comparable] struct {
+	repository.CrudRepository[T, ID]
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b blockingBackend[T, ID]) Save(id ID, entity T) error {
+	b.entered <- struct{}{}
+	<-b.release
+	return b.CrudRepository.Save(id, entity)
+}
+
+func TestRepository_FlushDeleteAll(t *testing.T) {
+	backend := mem.NewRepository[string, int]()
+	blocking := blockingBackend[string, int]{CrudRepository: backend, entered: make(chan struct{}), release: make(chan struct{})}
+	repo := NewRepository[string, int](blocking, WithMode(WriteBehind), WithFlushInterval(time.Hour))
+
+	if err := repo.Save(1, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	flushed := make(chan error)
+	go func() {
+		flushed <- repo.Flush()
+	}()
+
+	// the flush has read the pending entity and is about to save it, when DeleteAll runs
+	<-blocking.entered
+	deleted := make(chan error)
+	go func() {
+		deleted <- repo.DeleteAll()
+	}()
+
+	select {
+	case err := <-deleted:
+		t.Fatalf("expected DeleteAll to wait for the flush but got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(blocking.release)
+	if err := <-flushed; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-deleted; err != nil {
+		t.Fatal(err)
+	}
+
+	// the deleted entity must never come back
+	if n, err := backend.Count(); err != nil || n != 0 {
+		t.Fatalf("expected deleted entity but got %v %v", n, err)
+	}
+
+	if _, err := repo.FindByID(1); err == nil {
+		t.Fatal("expected deleted entity")
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,44 @@
+package cache
+
+import "sync"
+
+// keyMutex provides a reference counted mutex per key.
+type keyMutex[K comparable] struct {
+	mutex sync.Mutex
+	pool  map[K]*refMutex
+}
+
+type refMutex struct {
+	sync.Mutex
+	refs int
+}
+
+func (m *keyMutex[K]) lock(k K) {
+	m.mutex.Lock()
+	if m.pool == nil {
+		m.pool = map[K]*refMutex{}
+	}
+
+	l := m.pool[k]
+	if l == nil {
+		l = &refMutex{}
+		m.pool[k] = l
+	}
+	l.refs++
+	m.mutex.Unlock()
+
+	l.Lock()
+}
+
+func (m *keyMutex[K]) unlock(k K) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	l := m.pool[k]
+	l.refs--
+	if l.refs == 0 {
+		delete(m.pool, k)
+	}
+
+	l.Unlock()
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"github.com/golangee/repository/mem"
+	"time"
+)
+
+// Mode defines how saved entities are propagated into the backend.
+type Mode int
+
+const (
+	// WriteThrough saves into the backend before returning and updates the cache afterwards.
+	WriteThrough Mode = iota
+	// WriteBehind saves into the cache only and flushes into the backend asynchronously. Deletes are always
+	// applied synchronously.
+	WriteBehind
+)
+
+// Option configures a Repository.
+type Option func(*options)
+
+type options struct {
+	mode          Mode
+	flushInterval time.Duration
+	cache         []mem.Option
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		flushInterval: time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithMode sets the write strategy. Defaults to WriteThrough.
+func WithMode(mode Mode) Option {
+	return func(o *options) {
+		o.mode = mode
+	}
+}
+
+// WithFlushInterval sets the interval in which pending entities are written into the backend
+// when using WriteBehind. Defaults to one second.
+func WithFlushInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.flushInterval = d
+		}
+	}
+}
+
+// WithCache configures the underlying mem.Repository, e.g. to bound the cache using mem.WithMaxEntries or
+// to let cached entries expire using mem.WithTTL.
+func WithCache(opts ...mem.Option) Option {
+	return func(o *options) {
+		o.cache = append(o.cache, opts...)
+	}
+}
@@ -2,18 +2,23 @@ package fs
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/internal/lock"
 	"github.com/golangee/repository/iter"
 	"io"
 	"io/fs"
+	"path"
 	"reflect"
 	"strings"
 	"sync"
 )
 
 var InvalidFilename = errors.New("invalid file name")
+var ReadOnly = errors.New("repository is read-only")
 var byteType = reflect.TypeOf(byte(0))
 
 const (
@@ -21,6 +26,11 @@ const (
 	NAME_MAX = 255
 )
 
+const (
+	lockName = ".lock"
+	lockDir  = ".locks"
+)
+
 type Name interface {
 	~string
 }
@@ -33,7 +43,7 @@ type openFileMutex struct {
 // BlobRepository provides a simple fs based (not yet standardized) repository.
 // This repository works on POSIX systems and just relies on the filesystem for concurrent and atomic
 // read/write semantics which probably do not work on Windows.
-// Behavior is undefined, if a directory is shared between multiple repository instances.
+// Behavior is undefined, if a directory is shared between multiple repository instances, unless WithLocking is used.
 // The binary bytes of the ID is taken, hex encoded and used as the file name.
 // This works best with plain integers or byte arrays (like UUID).
 // Storing plain strings works, but is inefficient.
@@ -43,18 +53,99 @@ type openFileMutex struct {
 // This implementation is mostly useful for prototyping and testing and shall not replace any serious SQL or NOSQL
 // database. However, even though it may be slow, at least on POSIX it is considered to provide ACID properties.
 type BlobRepository[ID Name] struct {
-	fs   fs.FS
-	pool *rcMutexes[ID]
+	fs      fs.FS
+	pool    *rcMutexes[ID]
+	watcher watcher[ID]
+	opts    options
+	trash   trash
+	lock    io.Closer  // lock is the directory lock, if enabled
+	closing sync.Mutex // closing guards lock
+	locks   lock.Table[ID]
 }
 
-func NewBlobRepository[ID Name](fsys fs.FS) (*BlobRepository[ID], error) {
-	for prefix := byte(0); prefix < 0xff; prefix++ {
-		if err := MkdirAll(fsys, hex.EncodeToString([]byte{prefix})); err != nil {
+func NewBlobRepository[ID Name](fsys fs.FS, opts ...Option) (*BlobRepository[ID], error) {
+	o := newOptions(opts)
+	r := &BlobRepository[ID]{fs: fsys, pool: newRcMutexes[ID](), opts: o}
+	r.watcher.interval = o.pollInterval
+
+	if o.readOnly {
+		return r, nil
+	}
+
+	if err := r.initLocking(); err != nil {
+		return nil, err
+	}
+
+	for prefix := 0; prefix <= 0xff; prefix++ {
+		if err := MkdirAll(fsys, hex.EncodeToString([]byte{byte(prefix)})); err != nil {
+			_ = r.Close()
 			return nil, fmt.Errorf("cannot initialize fanout: %w", err)
 		}
 	}
 
-	return &BlobRepository[ID]{fs: fsys, pool: newRcMutexes[ID]()}, nil
+	if err := syncDir(fsys, "."); err != nil {
+		_ = r.Close()
+		return nil, fmt.Errorf("cannot sync fanout: %w", err)
+	}
+
+	if o.softDelete {
+		if _, err := r.PurgeDeleted(context.Background()); err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("cannot purge trash: %w", err)
+		}
+	}
+
+	return r, nil
+}
+
+// initLocking acquires the directory lock or installs the per ID file locks, as configured.
+func (r *BlobRepository[ID]) initLocking() error {
+	switch r.opts.locking {
+	case LockDirectory:
+		lock, err := LockFile(r.fs, lockName, true, false)
+		if err != nil {
+			return fmt.Errorf("cannot lock directory: %w", err)
+		}
+
+		r.lock = lock
+	case LockPerID:
+		r.pool.fileLock = func(id ID, exclusive bool) (io.Closer, error) {
+			name := lockFileName(id, ".lock")
+			if err := MkdirAll(r.fs, path.Dir(name)); err != nil {
+				return nil, err
+			}
+
+			return LockFile(r.fs, name, exclusive, true)
+		}
+	}
+
+	return nil
+}
+
+// lockFileName returns the name of the lock file of the id. The name is derived from the sha256 hash of the id
+// using the same fanout as the blobs, so that it never exceeds NAME_MAX, even if the id already has the maximum
+// length:
+//
+//	.locks/hex(sha256(id)[0])/hex(sha256(id))<ext>
+func lockFileName[ID Name](id ID, ext string) string {
+	sum := sha256.Sum256([]byte(id))
+
+	return lockDir + "/" + hex.EncodeToString(sum[:1]) + "/" + hex.EncodeToString(sum[:]) + ext
+}
+
+// Close releases the directory lock, if any. The repository must not be used afterwards.
+func (r *BlobRepository[ID]) Close() error {
+	r.closing.Lock()
+	defer r.closing.Unlock()
+
+	if r.lock == nil {
+		return nil
+	}
+
+	err := r.lock.Close()
+	r.lock = nil
+
+	return err
 }
 
 func (r *BlobRepository[ID]) assertEmptyMutexes() {
@@ -82,39 +173,184 @@ func (r *BlobRepository[ID]) Count(ctx context.Context) (int64, error) {
 	return count, nil
 }
 
+// Delete removes the given blob by id or moves it into the trash, if soft delete is enabled.
 func (r *BlobRepository[ID]) Delete(ctx context.Context, id ID) error {
+	if r.opts.readOnly {
+		return ReadOnly
+	}
+
+	r.maybePurge(ctx)
+
+	removed, err := r.delete(id)
+	if err != nil {
+		return err
+	}
+
+	if removed {
+		r.notify(repository.Deleted, id)
+	}
+
+	return nil
+}
+
+// delete removes or trashes the blob and returns true, if it has been removed. A missing blob is not an error.
+func (r *BlobRepository[ID]) delete(id ID) (bool, error) {
+	if !ValidName(id) {
+		return false, InvalidFilename
+	}
+
+	var removed bool
+	err := r.locked(id, func() error {
+		var err error
+		removed, err = r.remove(id)
+		return err
+	})
+
+	return removed, err
+}
+
+// remove is like delete, but the caller must hold the write lock of the id.
+func (r *BlobRepository[ID]) remove(id ID) (bool, error) {
+	if r.opts.softDelete {
+		return r.moveToTrash(id)
+	}
+
+	if err := Remove(r.fs, string(id)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	// the blob is already gone, but the removal may still be lost on power failure
+	return true, syncCommitted(r.fs, string(id))
+}
+
+// locked invokes f while holding the write lock of the id exclusively.
+func (r *BlobRepository[ID]) locked(id ID, f func() error) error {
+	m := r.pool.get(id)
+	m.inc()
+	defer m.dec()
+
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	return f()
+}
+
+// replace atomically writes the blob like Write, but the caller must hold the write lock of the id.
+func (r *BlobRepository[ID]) replace(id ID, buf []byte) error {
+	if err := replaceFile(r.fs, string(id), buf); err != nil {
+		return err
+	}
+
+	r.notify(repository.Saved, id)
+
+	return nil
+}
+
+// update performs an atomic read-modify-write of an existing blob while holding the lock exclusively, so that
+// concurrent writers cannot be lost. If f returns nil, the blob is left untouched. Returns true, if the blob
+// has been replaced.
+func (r *BlobRepository[ID]) update(id ID, f func(buf []byte) ([]byte, error)) (bool, error) {
+	if r.opts.readOnly {
+		return false, ReadOnly
+	}
+
 	if !ValidName(id) {
-		return InvalidFilename
+		return false, InvalidFilename
 	}
 
 	m := r.pool.get(id)
 	m.inc()
 	defer m.dec()
 
-	m.Lock()
-	defer m.Unlock()
+	unlock, err := m.lock()
+	if err != nil {
+		return false, err
+	}
+
+	defer unlock()
+
+	buf, err := fs.ReadFile(r.fs, string(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil // deleted concurrently
+		}
+
+		return false, err
+	}
+
+	buf, err = f(buf)
+	if err != nil || buf == nil {
+		return false, err
+	}
+
+	if err := replaceFile(r.fs, string(id), buf); err != nil {
+		return false, err
+	}
 
-	return Remove(r.fs, string(id))
+	r.notify(repository.Saved, id)
+
+	return true, nil
 }
 
+// DeleteAll removes all blobs or moves them into the trash, if soft delete is enabled. If it fails, a Deleted
+// event is published for each removed blob instead of a Cleared event.
 func (r *BlobRepository[ID]) DeleteAll(ctx context.Context) error {
+	if r.opts.readOnly {
+		return ReadOnly
+	}
+
+	r.maybePurge(ctx)
+
 	ids, err := r.FindAll(ctx)
 	if err != nil {
 		return err
 	}
 
-	return iter.Walk(ids, func(item ID) error {
-		return r.Delete(ctx, item)
+	var removed []ID
+	err = iter.Walk(ids, func(item ID) error {
+		ok, err := r.delete(item)
+		if ok {
+			removed = append(removed, item)
+		}
+
+		return err
 	})
+
+	// a partial deletion must not be reported as cleared, because the remaining blobs still exist
+	if err != nil {
+		for _, id := range removed {
+			r.notify(repository.Deleted, id)
+		}
+
+		return err
+	}
+
+	var zero ID
+	r.notify(repository.Cleared, zero)
+
+	return nil
 }
 
 func (r *BlobRepository[ID]) Write(ctx context.Context, id ID) (io.WriteCloser, error) {
+	if r.opts.readOnly {
+		return nil, ReadOnly
+	}
+
 	if !ValidName(id) {
 		return nil, InvalidFilename
 	}
 
-	return writeFile(r.fs, string(id), r.pool.get(id))
-
+	return writeFile(ctx, r.fs, string(id), r.pool.get(id), func() {
+		r.notify(repository.Saved, id)
+	})
 }
 
 func (r *BlobRepository[ID]) Read(ctx context.Context, id ID) (io.ReadCloser, error) {
@@ -125,6 +361,24 @@ func (r *BlobRepository[ID]) Read(ctx context.Context, id ID) (io.ReadCloser, er
 	return readFile(r.fs, string(id), r.pool.get(id))
 }
 
+// Size returns the size of the blob in bytes without reading it.
+func (r *BlobRepository[ID]) Size(ctx context.Context, id ID) (int64, error) {
+	if !ValidName(id) {
+		return 0, InvalidFilename
+	}
+
+	info, err := fs.Stat(r.fs, string(id))
+	if err != nil {
+		return 0, err
+	}
+
+	if info.IsDir() {
+		return 0, &fs.PathError{Op: "stat", Path: string(id), Err: fs.ErrNotExist}
+	}
+
+	return info.Size(), nil
+}
+
 // FindAll returns all blob identifiers. The current implementation buffers first the entire list of ids before
 // the iterator becomes available. Files with a leading . are ignored.
 func (r *BlobRepository[ID]) FindAll(ctx context.Context) (iter.Iterator[ID], error) {
@@ -142,27 +396,39 @@ func (r *BlobRepository[ID]) FindByPrefix(ctx context.Context, prefix string) (i
 	}
 
 	var res []ID
-	err := fs.WalkDir(r.fs, prefix, func(path string, d fs.DirEntry, err error) error {
+	err := r.walk(ctx, prefix, func(id ID, d fs.DirEntry) error {
+		res = append(res, id)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return iter.Iter(res), nil
+}
+
+// walk visits all blob files below the given prefix and skips hidden files and directories.
+func (r *BlobRepository[ID]) walk(ctx context.Context, prefix string, f func(id ID, d fs.DirEntry) error) error {
+	return fs.WalkDir(r.fs, prefix, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if d.IsDir() && strings.HasPrefix(d.Name(), ".") {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() && path != prefix && strings.HasPrefix(d.Name(), ".") {
 			return fs.SkipDir
 		}
 
-		if !d.IsDir() && !strings.HasSuffix(d.Name(), ".") {
-			res = append(res, ID(path))
+		if !d.IsDir() && !strings.HasPrefix(d.Name(), ".") {
+			return f(ID(path), d)
 		}
 
 		return nil
 	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return iter.Iter(res), nil
 }
 
 // ValidName returns false, if name does not apply to our rules of a safe name:
@@ -6,10 +6,16 @@ import (
 	"crypto/sha256"
 	"github.com/golangee/repository/iter"
 	"io"
+	"io/fs"
 	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"testing/fstest"
 )
 
 type testBlob struct {
@@ -17,9 +23,28 @@ type testBlob struct {
 	data []byte
 }
 
+// testFileSystems returns fresh os directories, an in-memory filesystem and an overlay with a seeded lower layer.
+func testFileSystems(t *testing.T) map[string]fs.FS {
+	return map[string]fs.FS{
+		"dir":      Dir(t.TempDir()),
+		"nofollow": DirNoFollow(t.TempDir()),
+		"mem":      NewMemFS(),
+		"overlay":  Overlay(fstest.MapFS{"seed/blob": {Data: []byte("seed")}}, NewMemFS()),
+	}
+}
+
 func Test_blobRepoRaces(t *testing.T) {
+	for name, fsys := range testFileSystems(t) {
+		fsys := fsys
+		t.Run(name, func(t *testing.T) {
+			testBlobRepoRaces(t, fsys)
+		})
+	}
+}
+
+func testBlobRepoRaces(t *testing.T, fsys fs.FS) {
 	ctx := context.Background()
-	repo := must(NewBlobRepository(Dir(t.TempDir())))
+	repo := must(NewBlobRepository[string](fsys))
 	must("", repo.DeleteAll(ctx))
 
 	const (
@@ -36,7 +61,7 @@ func Test_blobRepoRaces(t *testing.T) {
 			defer wg.Done()
 
 			name := "racy" + strconv.Itoa(concurrency%maxFiles)
-			blob := blobs[i]
+			blob := blobs[n]
 			w := must(repo.Write(ctx, name))
 			must(w.Write(blob.data))
 			must("", w.Close())
@@ -64,8 +89,17 @@ func Test_blobRepoRaces(t *testing.T) {
 }
 
 func Test_blobRepo(t *testing.T) {
+	for name, fsys := range testFileSystems(t) {
+		fsys := fsys
+		t.Run(name, func(t *testing.T) {
+			testBlobRepo(t, fsys)
+		})
+	}
+}
+
+func testBlobRepo(t *testing.T, fsys fs.FS) {
 	ctx := context.Background()
-	repo := must(NewBlobRepository(Dir(t.TempDir())))
+	repo := must(NewBlobRepository[string](fsys))
 	must("", repo.DeleteAll(ctx))
 	if n := must(repo.Count(ctx)); n != 0 {
 		t.Fatalf("expected 0 bot got %v", n)
@@ -153,9 +187,9 @@ func genTestBlobs(count int) []testBlob {
 	})
 
 	// calc standalone checksums
-	for _, re := range res {
-		sum := sha256.Sum256(re.data)
-		re.data = append(re.data, sum[:]...)
+	for i := range res {
+		sum := sha256.Sum256(res[i].data)
+		res[i].data = append(res[i].data, sum[:]...)
 	}
 
 	return res
@@ -202,3 +236,70 @@ func Test_validName(t *testing.T) {
 		})
 	}
 }
+
+func TestNewBlobRepository_fanout(t *testing.T) {
+	fsys := NewMemFS()
+	must(NewBlobRepository[string](fsys))
+
+	entries := must(fs.ReadDir(fsys, "."))
+	if len(entries) != 256 {
+		t.Fatalf("expected 256 fanout directories but got %v", len(entries))
+	}
+
+	// the last prefix must be created as well
+	for _, name := range []string{"00", "ff"} {
+		if info, err := fs.Stat(fsys, name); err != nil || !info.IsDir() {
+			t.Fatalf("expected fanout directory %v but got %v", name, err)
+		}
+	}
+}
+
+func Test_tempName(t *testing.T) {
+	names := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		name := tempName("ab/cd/", "blob")
+		if !strings.HasPrefix(name, "ab/cd/.blob.") || !strings.HasSuffix(name, ".tmp") {
+			t.Fatalf("expected hidden temporary file in target directory but got %v", name)
+		}
+
+		if names[name] {
+			t.Fatalf("expected unique temporary file names but got %v twice", name)
+		}
+
+		names[name] = true
+	}
+
+	if name := tempName("ab/", strings.Repeat("b", NAME_MAX)); len(path.Base(name)) > NAME_MAX {
+		t.Fatalf("expected temporary file name within NAME_MAX but got %v", len(path.Base(name)))
+	}
+}
+
+func Test_blobRepoDeleteMissing(t *testing.T) {
+	ctx := context.Background()
+	repo := must(NewBlobRepository[string](NewMemFS()))
+
+	// deleting is idempotent, like in the other repository implementations
+	must("", repo.Delete(ctx, "missing"))
+	must("", repo.Delete(ctx, "00/missing"))
+}
+
+func Test_blobRepoHidden(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := must(NewBlobRepository[string](Dir(dir)))
+
+	// hidden files are temporary files, revisions or internal state and never blobs
+	must("", os.MkdirAll(filepath.Join(dir, ".hidden"), 0700))
+	for _, name := range []string{".root", "00/.nested", ".hidden/file", "00/visible"} {
+		must("", os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(name), 0600))
+	}
+
+	ids := must(iter.Collect(must(repo.FindAll(ctx))))
+	if len(ids) != 1 || ids[0] != "00/visible" {
+		t.Fatalf("expected only visible blob but got %v", ids)
+	}
+
+	if n := must(repo.Count(ctx)); n != 1 {
+		t.Fatalf("expected 1 blob but got %v", n)
+	}
+}
@@ -0,0 +1,405 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// Crashed is returned by all operations of a FaultFS after it has crashed.
+var Crashed = errors.New("filesystem crashed")
+
+// Operation is a single recorded operation of a FaultFS.
+type Operation struct {
+	Seq  int    // Seq is the 1-based sequence number, which can be passed to FailAt.
+	Op   string // Op is one of the Fault operations or "close".
+	Name string
+	Err  error // Err is the returned error, either injected or from the wrapped filesystem.
+}
+
+// FaultFS wraps a writeable filesystem to test crash consistency. It counts and records all mutating operations,
+// can fail an arbitrary operation and simulates a power loss by dropping all data which has not been synced.
+// Renames, removals and created directories are considered durable immediately, however a renamed file only
+// carries its synced content. Reads are neither counted nor recorded. FaultFS serializes all operations.
+// After a crash, the wrapped filesystem represents the state after a reboot and can be inspected directly.
+type FaultFS struct {
+	fsys fs.FS
+
+	mutex   sync.Mutex
+	seq     int
+	failAt  int
+	failErr error
+	crashAt int
+	down    bool
+	trace   []Operation
+	dirty   map[string]durable // dirty contains the last synced state of all files with un-synced data
+	files   map[*faultFile]struct{}
+}
+
+type durable struct {
+	data    []byte
+	existed bool // existed is false, if the file has never been synced and vanishes on a crash
+}
+
+// NewFaultFS wraps the given filesystem, which must support the write interfaces of this package.
+func NewFaultFS(fsys fs.FS) *FaultFS {
+	return &FaultFS{fsys: fsys, dirty: map[string]durable{}, files: map[*faultFile]struct{}{}}
+}
+
+// FailAt lets the n-th operation, counted since the creation of the filesystem, fail with the given error.
+// A value <= 0 disables the failure.
+func (f *FaultFS) FailAt(n int, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.failAt, f.failErr = n, err
+}
+
+// CrashAt lets the filesystem crash right before the n-th operation, which is like a process dying at an arbitrary
+// point. Neither the n-th nor any later operation is executed. A value <= 0 disables the crash.
+func (f *FaultFS) CrashAt(n int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.crashAt = n
+}
+
+// Trace returns a copy of all recorded operations.
+func (f *FaultFS) Trace() []Operation {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]Operation(nil), f.trace...)
+}
+
+// Crash simulates a power loss: all un-synced data is dropped from the wrapped filesystem and any further operation
+// fails with Crashed.
+func (f *FaultFS) Crash() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.crash()
+}
+
+// crash drops all un-synced data. The caller must hold the lock.
+func (f *FaultFS) crash() error {
+	if f.down {
+		return nil
+	}
+
+	f.down = true
+	for file := range f.files {
+		_ = file.file.Close()
+		file.crashed = true
+	}
+
+	f.files = map[*faultFile]struct{}{}
+
+	for name, d := range f.dirty {
+		if !d.existed {
+			if err := Remove(f.fsys, name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+
+			continue
+		}
+
+		file, err := OpenFile(f.fsys, name, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+
+		w, ok := file.(WriteableFile)
+		if !ok {
+			_ = file.Close()
+			return WriteableFileNotSupported
+		}
+
+		if _, err := w.Write(d.data); err != nil {
+			_ = w.Close()
+			return err
+		}
+
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+
+	f.dirty = map[string]durable{}
+
+	return nil
+}
+
+// record counts the operation, injects the failure or crash if due and records the result of fn. The caller must
+// hold the lock.
+func (f *FaultFS) record(op, name string, fn func() error) error {
+	if f.down {
+		return &fs.PathError{Op: op, Path: name, Err: Crashed}
+	}
+
+	f.seq++
+	if f.seq == f.crashAt {
+		err := f.crash()
+		if err == nil {
+			err = &fs.PathError{Op: op, Path: name, Err: Crashed}
+		}
+
+		f.trace = append(f.trace, Operation{Seq: f.seq, Op: op, Name: name, Err: err})
+
+		return err
+	}
+
+	var err error
+	if f.seq == f.failAt {
+		err = &fs.PathError{Op: op, Path: name, Err: f.failErr}
+	} else {
+		err = fn()
+	}
+
+	f.trace = append(f.trace, Operation{Seq: f.seq, Op: op, Name: name, Err: err})
+
+	return err
+}
+
+func (f *FaultFS) Open(name string) (fs.File, error) {
+	if err := f.alive("open", name); err != nil {
+		return nil, err
+	}
+
+	return f.fsys.Open(name)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (f *FaultFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := f.alive("readdir", name); err != nil {
+		return nil, err
+	}
+
+	return fs.ReadDir(f.fsys, name)
+}
+
+// Stat implements fs.StatFS.
+func (f *FaultFS) Stat(name string) (fs.FileInfo, error) {
+	if err := f.alive("stat", name); err != nil {
+		return nil, err
+	}
+
+	return fs.Stat(f.fsys, name)
+}
+
+// alive returns Crashed for read operations after a crash.
+func (f *FaultFS) alive(op, name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.down {
+		return &fs.PathError{Op: op, Path: name, Err: Crashed}
+	}
+
+	return nil
+}
+
+func (f *FaultFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	writeable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	var file fs.File
+	err := f.record(FaultOpen, name, func() (err error) {
+		if writeable {
+			f.markDirty(name)
+		}
+
+		file, err = OpenFile(f.fsys, name, flag, perm)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !writeable {
+		return file, nil
+	}
+
+	ff := &faultFile{fs: f, file: file, name: name}
+	f.files[ff] = struct{}{}
+
+	return ff, nil
+}
+
+// markDirty remembers the current content of the file as its synced state, if not yet dirty. The caller must
+// hold the lock.
+func (f *FaultFS) markDirty(name string) {
+	if _, ok := f.dirty[name]; !ok {
+		data, err := fs.ReadFile(f.fsys, name)
+		f.dirty[name] = durable{data: data, existed: err == nil}
+	}
+}
+
+func (f *FaultFS) Remove(name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.record(FaultRemove, name, func() error {
+		if err := Remove(f.fsys, name); err != nil {
+			return err
+		}
+
+		delete(f.dirty, name)
+
+		return nil
+	})
+}
+
+func (f *FaultFS) Rename(oldpath, newpath string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.record(FaultRename, newpath, func() error {
+		if err := Rename(f.fsys, oldpath, newpath); err != nil {
+			return err
+		}
+
+		if d, ok := f.dirty[oldpath]; ok {
+			// the renamed inode only carries its synced content, e.g. a zero length file
+			f.dirty[newpath] = durable{data: d.data, existed: true}
+			delete(f.dirty, oldpath)
+		} else {
+			delete(f.dirty, newpath)
+		}
+
+		return nil
+	})
+}
+
+func (f *FaultFS) MkdirAll(name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.record(FaultMkdir, name, func() error {
+		return MkdirAll(f.fsys, name)
+	})
+}
+
+// SyncDir delegates to the wrapped filesystem. Renames are durable anyway, so this is only counted and recorded.
+func (f *FaultFS) SyncDir(name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.record(FaultSyncDir, name, func() error {
+		return SyncDir(f.fsys, name)
+	})
+}
+
+// LockFile delegates to the wrapped filesystem without being counted or recorded.
+func (f *FaultFS) LockFile(name string, exclusive, wait bool) (io.Closer, error) {
+	if err := f.alive("lock", name); err != nil {
+		return nil, err
+	}
+
+	return LockFile(f.fsys, name, exclusive, wait)
+}
+
+// Write delegates to the wrapped filesystem, which must write transactionally and durable.
+func (f *FaultFS) Write(name string, w func(w io.Writer) error) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.record(FaultWrite, name, func() error {
+		if err := Write(f.fsys, name, w); err != nil {
+			return err
+		}
+
+		delete(f.dirty, name)
+
+		return nil
+	})
+}
+
+// faultFile is a writeable file of a FaultFS.
+type faultFile struct {
+	fs      *FaultFS
+	file    fs.File
+	name    string
+	crashed bool
+}
+
+func (f *faultFile) Stat() (fs.FileInfo, error) {
+	return f.file.Stat()
+}
+
+func (f *faultFile) Read(p []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.crashed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: Crashed}
+	}
+
+	return f.file.Read(p)
+}
+
+func (f *faultFile) Write(p []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.crashed {
+		return 0, &fs.PathError{Op: FaultWrite, Path: f.name, Err: Crashed}
+	}
+
+	var n int
+	err := f.fs.record(FaultWrite, f.name, func() (err error) {
+		w, ok := f.file.(io.Writer)
+		if !ok {
+			return WriteableFileNotSupported
+		}
+
+		f.fs.markDirty(f.name)
+		n, err = w.Write(p)
+		return err
+	})
+
+	return n, err
+}
+
+func (f *faultFile) Sync() error {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.crashed {
+		return &fs.PathError{Op: FaultSync, Path: f.name, Err: Crashed}
+	}
+
+	return f.fs.record(FaultSync, f.name, func() error {
+		if syncer, ok := f.file.(SyncableFile); ok {
+			if err := syncer.Sync(); err != nil {
+				return err
+			}
+		}
+
+		delete(f.fs.dirty, f.name)
+
+		return nil
+	})
+}
+
+// Close closes the wrapped file even if a failure is injected, like a delayed write error reported by close.
+func (f *faultFile) Close() error {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.crashed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: Crashed}
+	}
+
+	delete(f.fs.files, f)
+
+	closeErr := f.file.Close()
+
+	return f.fs.record("close", f.name, func() error {
+		return closeErr
+	})
+}
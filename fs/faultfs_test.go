@@ -0,0 +1,315 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/iter"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// crashStep is a single modification of the crash consistency workload. A nil data deletes the blob.
+type crashStep struct {
+	id   string
+	data []byte
+}
+
+var crashWorkload = []crashStep{
+	{id: "a", data: []byte("a1")},
+	{id: "b/c", data: []byte("c1")},
+	{id: "a", data: []byte("a2 which is longer")},
+	{id: "b/c", data: nil},
+	{id: "d", data: []byte("d1")},
+	{id: "a", data: nil},
+}
+
+func (s crashStep) apply(ctx context.Context, repo *BlobRepository[string]) error {
+	if s.data == nil {
+		return repo.Delete(ctx, s.id)
+	}
+
+	w, err := repo.Write(ctx, s.id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(s.data); err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// expectedState returns the blobs after applying the given steps in memory.
+func expectedState(steps []crashStep) map[string]string {
+	res := map[string]string{}
+	for _, s := range steps {
+		if s.data == nil {
+			delete(res, s.id)
+		} else {
+			res[s.id] = string(s.data)
+		}
+	}
+
+	return res
+}
+
+// blobState reboots a repository on the given filesystem, checks its basic invariants and returns all blobs.
+func blobState(t *testing.T, fsys fs.FS) map[string]string {
+	t.Helper()
+
+	ctx := context.Background()
+	repo := must(NewBlobRepository[string](fsys))
+	ids := must(iter.Collect(must(repo.FindAll(ctx))))
+	if n := must(repo.Count(ctx)); n != int64(len(ids)) {
+		t.Fatalf("expected count %v but got %v", len(ids), n)
+	}
+
+	res := map[string]string{}
+	for _, id := range ids {
+		r := must(repo.Read(ctx, id))
+		buf := must(io.ReadAll(r))
+		must("", r.Close())
+
+		if size := must(repo.Size(ctx, id)); size != int64(len(buf)) {
+			t.Fatalf("%s: expected size %v but got %v", id, len(buf), size)
+		}
+
+		res[id] = string(buf)
+	}
+
+	// the rebooted repository is still writeable
+	must("", crashStep{id: "z", data: []byte("z")}.apply(ctx, repo))
+	must("", repo.Delete(ctx, "z"))
+	repo.assertEmptyMutexes()
+
+	return res
+}
+
+// newFaultRepository returns a repository on a fresh FaultFS and the amount of operations used for setup.
+func newFaultRepository() (*MemFS, *FaultFS, *BlobRepository[string], int) {
+	m := NewMemFS()
+	f := NewFaultFS(m)
+	repo := must(NewBlobRepository[string](f))
+
+	return m, f, repo, len(f.Trace())
+}
+
+func TestFaultFS(t *testing.T) {
+	ctx := context.Background()
+	_, f, repo, setup := newFaultRepository()
+	for _, s := range crashWorkload {
+		must("", s.apply(ctx, repo))
+	}
+
+	ops := map[string]bool{}
+	for i, op := range f.Trace() {
+		if op.Seq != i+1 || op.Err != nil {
+			t.Fatalf("unexpected trace %v", op)
+		}
+
+		ops[op.Op] = true
+	}
+
+	for _, op := range []string{FaultMkdir, FaultOpen, FaultWrite, FaultSync, "close", FaultRename, FaultRemove, FaultSyncDir} {
+		if !ops[op] {
+			t.Fatalf("expected %s in trace", op)
+		}
+	}
+
+	if len(f.Trace()) == setup {
+		t.Fatal("expected traced workload")
+	}
+
+	// un-synced data is lost, synced data survives
+	file := must(f.OpenFile("x", os.O_WRONLY|os.O_CREATE, 0600)).(WriteableFile)
+	must(file.Write([]byte("x")))
+	must("", file.Close())
+	file = must(f.OpenFile("y", os.O_WRONLY|os.O_CREATE, 0600)).(WriteableFile)
+	must(file.Write([]byte("y")))
+	must("", file.(SyncableFile).Sync())
+	must(file.Write([]byte("y")))
+	must("", f.Rename("y", "w"))
+	must("", f.Crash())
+
+	if _, err := f.Stat("w"); !errors.Is(err, Crashed) {
+		t.Fatalf("expected crashed but got %v", err)
+	}
+
+	if _, err := file.Write([]byte("y")); !errors.Is(err, Crashed) {
+		t.Fatalf("expected crashed but got %v", err)
+	}
+
+	if _, err := fs.Stat(f.fsys, "x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected lost file but got %v", err)
+	}
+
+	if buf := must(fs.ReadFile(f.fsys, "w")); string(buf) != "y" {
+		t.Fatalf("expected synced content but got %q", buf)
+	}
+}
+
+// TestBlobRepository_CrashConsistency kills the process before each operation of the workload and checks after a
+// reboot, that all completed steps are durable and the interrupted step is either fully applied or not at all.
+func TestBlobRepository_CrashConsistency(t *testing.T) {
+	ctx := context.Background()
+	_, f, repo, setup := newFaultRepository()
+	for _, s := range crashWorkload {
+		must("", s.apply(ctx, repo))
+	}
+
+	total := len(f.Trace()) - setup
+	for n := 1; n <= total; n++ {
+		m, f, repo, setup := newFaultRepository()
+		f.CrashAt(setup + n)
+
+		done := 0
+		for _, s := range crashWorkload {
+			err := s.apply(ctx, repo)
+			if err != nil {
+				if !errors.Is(err, Crashed) {
+					t.Fatalf("crash at %d: unexpected error %v", n, err)
+				}
+
+				break
+			}
+
+			done++
+		}
+
+		if done == len(crashWorkload) {
+			t.Fatalf("crash at %d: expected crash", n)
+		}
+
+		state := blobState(t, m)
+		before := expectedState(crashWorkload[:done])
+		after := expectedState(crashWorkload[:done+1])
+		if !reflect.DeepEqual(state, before) && !reflect.DeepEqual(state, after) {
+			t.Fatalf("crash at %d (%v): expected %v or %v but got %v", n, f.Trace()[setup+n-1], before, after, state)
+		}
+	}
+}
+
+// TestBlobRepository_FailureConsistency fails each operation of the workload once, e.g. a sync, close or rename,
+// and checks, that at most the affected step is missing and no temporary files are left.
+func TestBlobRepository_FailureConsistency(t *testing.T) {
+	ctx := context.Background()
+	_, f, repo, setup := newFaultRepository()
+	for _, s := range crashWorkload {
+		must("", s.apply(ctx, repo))
+	}
+
+	injected := errors.New("injected")
+	total := len(f.Trace()) - setup
+	for n := 1; n <= total; n++ {
+		m, f, repo, setup := newFaultRepository()
+		f.FailAt(setup+n, injected)
+
+		var applied []crashStep
+		failed := 0
+		for _, s := range crashWorkload {
+			if err := s.apply(ctx, repo); err != nil {
+				if !errors.Is(err, injected) {
+					t.Fatalf("fail at %d: unexpected error %v", n, err)
+				}
+
+				failed++
+				continue
+			}
+
+			applied = append(applied, s)
+		}
+
+		if failed != 1 {
+			t.Fatalf("fail at %d (%v): expected a single failed step but got %d", n, f.Trace()[setup+n-1], failed)
+		}
+
+		// a failed directory sync is reported after the rename, so the failed step may be visible anyway
+		repo.assertEmptyMutexes()
+		before, after := expectedState(applied), expectedState(crashWorkload)
+		if state := blobState(t, m); !reflect.DeepEqual(state, before) && !reflect.DeepEqual(state, after) {
+			t.Fatalf("fail at %d: expected %v or %v but got %v", n, before, after, state)
+		}
+
+		must("", fs.WalkDir(m, ".", func(path string, d fs.DirEntry, err error) error {
+			if strings.HasSuffix(path, ".tmp") {
+				t.Fatalf("fail at %d: unexpected leftover %v", n, path)
+			}
+
+			return err
+		}))
+	}
+}
+
+// TestBlobRepository_SyncDir checks, that each rename and each created directory is flushed by its parent.
+func TestBlobRepository_SyncDir(t *testing.T) {
+	ctx := context.Background()
+	_, f, repo, setup := newFaultRepository()
+	if last := f.Trace()[setup-1]; last.Op != FaultSyncDir || last.Name != "." {
+		t.Fatalf("expected fanout sync but got %v", last)
+	}
+
+	must("", crashStep{id: "b/c", data: []byte("c")}.apply(ctx, repo))
+	must("", crashStep{id: "b/c"}.apply(ctx, repo))
+
+	var ops []string
+	for _, op := range f.Trace()[setup:] {
+		if op.Op == FaultMkdir || op.Op == FaultRename || op.Op == FaultRemove || op.Op == FaultSyncDir {
+			ops = append(ops, op.Op+" "+op.Name)
+		}
+	}
+
+	expected := []string{"mkdir b", "syncdir .", "rename b/c", "syncdir b", "remove b/c", "syncdir b"}
+	if !reflect.DeepEqual(ops, expected) {
+		t.Fatalf("expected %v but got %v", expected, ops)
+	}
+}
+
+// TestBlobRepository_NotDurable checks, that a failed directory sync of an applied change is distinguishable and
+// that the change is not published as committed.
+func TestBlobRepository_NotDurable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewMemFS()
+	repo := must(NewBlobRepository[string](m, WithPollInterval(time.Hour)))
+	events := must(repo.Watch(ctx, repository.WatchOptions{}))
+
+	injected := errors.New("injected")
+	m.Inject(func(op, name string) error {
+		if op == FaultSyncDir && name == "b" {
+			return injected
+		}
+
+		return nil
+	})
+
+	if err := (crashStep{id: "b/c", data: []byte("c")}).apply(ctx, repo); !errors.Is(err, NotDurable) || !errors.Is(err, injected) {
+		t.Fatalf("expected not durable write but got %v", err)
+	}
+
+	if state := blobState(t, m); state["b/c"] != "c" {
+		t.Fatalf("expected visible write but got %v", state)
+	}
+
+	select {
+	case evt := <-events:
+		t.Fatalf("unexpected event %v %v", evt.Type, evt.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := repo.Delete(ctx, "b/c"); !errors.Is(err, NotDurable) {
+		t.Fatalf("expected not durable delete but got %v", err)
+	}
+
+	if state := blobState(t, m); len(state) != 0 {
+		t.Fatalf("expected removed blob but got %v", state)
+	}
+}
@@ -1,18 +1,25 @@
 package fs
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type rcMutexes[K comparable] struct {
-	pool map[K]*rcMutex
-	lock sync.Mutex
+	pool     map[K]*rcMutex
+	lock     sync.Mutex
+	fileLock func(k K, exclusive bool) (io.Closer, error) // fileLock optionally coordinates other processes
 }
 
 func newRcMutexes[K comparable]() *rcMutexes[K] {
@@ -33,6 +40,13 @@ func (m *rcMutexes[K]) get(k K) *rcMutex {
 				delete(m.pool, k)
 			},
 		}
+
+		if m.fileLock != nil {
+			mutex.fileLock = func(exclusive bool) (io.Closer, error) {
+				return m.fileLock(k, exclusive)
+			}
+		}
+
 		m.pool[k] = mutex
 	}
 
@@ -41,9 +55,41 @@ func (m *rcMutexes[K]) get(k K) *rcMutex {
 
 type rcMutex struct {
 	sync.RWMutex
-	rcLock  sync.Mutex
-	rc      int
-	destroy func(self *rcMutex)
+	rcLock   sync.Mutex
+	rc       int
+	destroy  func(self *rcMutex)
+	fileLock func(exclusive bool) (io.Closer, error)
+}
+
+// lock acquires the write lock and the exclusive file lock, if configured, and returns the unlock function.
+func (r *rcMutex) lock() (func(), error) {
+	r.Lock()
+	return r.lockFile(true, r.Unlock)
+}
+
+// rlock acquires the read lock and the shared file lock, if configured, and returns the unlock function.
+func (r *rcMutex) rlock() (func(), error) {
+	r.RLock()
+	return r.lockFile(false, r.RUnlock)
+}
+
+// lockFile acquires the file lock while already holding the given in-process lock, so that the goroutines of
+// this process never contend for the file lock.
+func (r *rcMutex) lockFile(exclusive bool, unlock func()) (func(), error) {
+	if r.fileLock == nil {
+		return unlock, nil
+	}
+
+	file, err := r.fileLock(exclusive)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	return func() {
+		_ = file.Close()
+		unlock()
+	}, nil
 }
 
 func (r *rcMutex) inc() {
@@ -68,23 +114,38 @@ func (r *rcMutex) dec() {
 
 // fileReadCloser only provides concurrent read access.
 type fileReadCloser struct {
-	mutex *rcMutex
-	file  fs.File
+	mutex  *rcMutex
+	unlock func()
+	file   fs.File
+	closed bool
 }
 
 func readFile(fsys fs.FS, name string, mutex *rcMutex) (*fileReadCloser, error) {
 	mutex.inc()
-	mutex.RLock() // lock before, to avoid races
+	unlock, err := mutex.rlock() // lock before, to avoid races
+	if err != nil {
+		mutex.dec()
+		return nil, err
+	}
+
 	file, err := OpenFile(fsys, name, os.O_RDONLY, 0)
+	if err == nil {
+		if info, e := file.Stat(); e == nil && info.IsDir() {
+			_ = file.Close()
+			err = &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist} // a directory is never a blob
+		}
+	}
+
 	if err != nil {
-		mutex.RUnlock() // unlock, e.g. file does not exist
+		unlock() // unlock, e.g. file does not exist
 		mutex.dec()
 		return nil, err
 	}
 
 	return &fileReadCloser{
-		mutex: mutex,
-		file:  file,
+		mutex:  mutex,
+		unlock: unlock,
+		file:   file,
 	}, nil
 }
 
@@ -92,9 +153,15 @@ func (f *fileReadCloser) Read(p []byte) (n int, err error) {
 	return f.file.Read(p)
 }
 
+// Close releases the read lock. Closing twice returns fs.ErrClosed, like os.File does.
 func (f *fileReadCloser) Close() error {
+	if f.closed {
+		return fs.ErrClosed
+	}
+
+	f.closed = true
 	defer f.mutex.dec()
-	defer f.mutex.RUnlock()
+	defer f.unlock()
 	if closer, ok := f.file.(io.Closer); ok {
 		return closer.Close()
 	}
@@ -102,20 +169,46 @@ func (f *fileReadCloser) Close() error {
 	return nil
 }
 
+// tmpCounter makes temporary file names unique, even if created within the same microsecond.
+var tmpCounter int64
+
+// tempName returns a unique hidden name within the same directory, so that the rename is atomic. A base, which
+// would exceed NAME_MAX, is replaced by its hash.
+func tempName(dir, base string) string {
+	suffix := "." + strconv.FormatInt(time.Now().UnixMicro(), 10) + "." + strconv.FormatInt(atomic.AddInt64(&tmpCounter, 1), 10) + ".tmp"
+	if len(base)+len(suffix)+1 > NAME_MAX {
+		sum := sha256.Sum256([]byte(base))
+		base = hex.EncodeToString(sum[:16])
+	}
+
+	return dir + "." + base + suffix
+}
+
 // fileWriteCloser writes into a temporary file and locks the file writeable only when committing, forcing
 // any other read locks to close before. This ensures most portable cross-platform behavior for atomic renames,
 // especially on systems without posix unlink semantic like windows.
 type fileWriteCloser struct {
+	ctx     context.Context
 	mutex   *rcMutex
 	fsys    fs.FS
 	dstName string
 	tmpName string
 	tmpFile WriteableFile
+	commit  func() // commit is invoked after a successful rename while still holding the lock
+	err     error  // err is the first failed write, which discards the file when closing
 }
 
-func writeFile(fsys fs.FS, name string, mutex *rcMutex) (*fileWriteCloser, error) {
+func writeFile(ctx context.Context, fsys fs.FS, name string, mutex *rcMutex, commit func()) (*fileWriteCloser, error) {
 	mutex.inc() // ensure mutex live time
-	tmpName := "." + name + "." + strconv.FormatInt(time.Now().UnixMicro(), 10) + ".tmp"
+	dir, base := path.Split(name)
+	if dir != "" {
+		if err := mkdirAllDurable(fsys, path.Dir(name)); err != nil {
+			mutex.dec()
+			return nil, err
+		}
+	}
+
+	tmpName := tempName(dir, base)
 	file, err := OpenFile(fsys, tmpName, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0600)
 	if err != nil {
 		mutex.dec()
@@ -124,26 +217,64 @@ func writeFile(fsys fs.FS, name string, mutex *rcMutex) (*fileWriteCloser, error
 
 	if w, ok := file.(WriteableFile); ok {
 		return &fileWriteCloser{
+			ctx:     ctx,
 			mutex:   mutex,
 			tmpName: tmpName,
 			tmpFile: w,
 			dstName: name,
 			fsys:    fsys,
+			commit:  commit,
 		}, nil
 	}
 
+	_ = file.Close()
+	_ = Remove(fsys, tmpName)
+	mutex.dec()
+
 	return nil, WriteableFileNotSupported
 }
 
 func (f *fileWriteCloser) Write(p []byte) (n int, err error) {
-	return f.tmpFile.Write(p)
+	if f.err != nil {
+		return 0, f.err
+	}
+
+	if err := f.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err = f.tmpFile.Write(p)
+	if err != nil {
+		f.err = err
+	}
+
+	return n, err
 }
 
-func (f *fileWriteCloser) Close() error {
+// Close commits the written data, unless the context has been cancelled or a write has failed. In that case,
+// or if committing fails, the temporary file is removed.
+func (f *fileWriteCloser) Close() (err error) {
 	defer f.mutex.dec() //free mutex
 
+	defer func() {
+		if err != nil {
+			_ = Remove(f.fsys, f.tmpName)
+		}
+	}()
+
+	if f.err != nil {
+		_ = f.tmpFile.Close()
+		return f.err
+	}
+
+	if err := f.ctx.Err(); err != nil {
+		_ = f.tmpFile.Close()
+		return err
+	}
+
 	if syncer, ok := f.tmpFile.(SyncableFile); ok {
 		if err := syncer.Sync(); err != nil {
+			_ = f.tmpFile.Close()
 			return fmt.Errorf("fsync failed on temporary file: %w", err)
 		}
 	}
@@ -152,12 +283,124 @@ func (f *fileWriteCloser) Close() error {
 		return fmt.Errorf("cannot close temporary file: %w", err)
 	}
 
-	f.mutex.Lock() // acquire the write-lock, waiting that all readers are closed on shared mutex
-	defer f.mutex.Unlock()
+	unlock, err := f.mutex.lock() // acquire the write-lock, waiting that all readers are closed on shared mutex
+	if err != nil {
+		return fmt.Errorf("cannot lock file %s: %w", f.dstName, err)
+	}
+
+	defer unlock()
 
 	if err := Rename(f.fsys, f.tmpName, f.dstName); err != nil {
 		return fmt.Errorf("cannot rename file %s -> %s: %w", f.tmpName, f.dstName, err)
 	}
 
+	// the blob is already visible, but the rename may still be lost on power failure, so commit only afterwards
+	if err := syncCommitted(f.fsys, f.dstName); err != nil {
+		return err
+	}
+
+	if f.commit != nil {
+		f.commit()
+	}
+
+	return nil
+}
+
+// replaceFile atomically replaces the named file with the given data. In contrast to writeFile, the caller must
+// already hold the write lock.
+func replaceFile(fsys fs.FS, name string, data []byte) (err error) {
+	tmpName := tempName(path.Split(name))
+	file, err := OpenFile(fsys, tmpName, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = Remove(fsys, tmpName)
+		}
+	}()
+
+	w, ok := file.(WriteableFile)
+	if !ok {
+		_ = file.Close()
+		return WriteableFileNotSupported
+	}
+
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	if syncer, ok := w.(SyncableFile); ok {
+		if err := syncer.Sync(); err != nil {
+			_ = w.Close()
+			return fmt.Errorf("fsync failed on temporary file: %w", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("cannot close temporary file: %w", err)
+	}
+
+	if err := Rename(fsys, tmpName, name); err != nil {
+		return fmt.Errorf("cannot rename file %s -> %s: %w", tmpName, name, err)
+	}
+
+	return syncCommitted(fsys, name)
+}
+
+// syncDir flushes the entries of the named directory, if the filesystem requires that.
+func syncDir(fsys fs.FS, name string) error {
+	if err := SyncDir(fsys, name); err != nil && !errors.Is(err, SyncDirNotSupported) {
+		return err
+	}
+
+	return nil
+}
+
+// syncCommitted flushes the directory of an already applied change of the named file. A failure is reported
+// as NotDurable, because the change is visible anyway.
+func syncCommitted(fsys fs.FS, name string) error {
+	if err := syncDir(fsys, path.Dir(name)); err != nil {
+		return notDurable(fmt.Errorf("cannot sync directory of %s: %w", name, err))
+	}
+
+	return nil
+}
+
+// renameDurable renames the file and flushes both directories.
+func renameDurable(fsys fs.FS, oldpath, newpath string) error {
+	if err := Rename(fsys, oldpath, newpath); err != nil {
+		return err
+	}
+
+	if err := syncCommitted(fsys, newpath); err != nil {
+		return err
+	}
+
+	if path.Dir(oldpath) != path.Dir(newpath) {
+		return syncCommitted(fsys, oldpath)
+	}
+
+	return nil
+}
+
+// mkdirAllDurable is like MkdirAll but also flushes all parents, if the directory did not exist before.
+func mkdirAllDurable(fsys fs.FS, name string) error {
+	if info, err := fs.Stat(fsys, name); err == nil && info.IsDir() {
+		return nil
+	}
+
+	if err := MkdirAll(fsys, name); err != nil {
+		return err
+	}
+
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if err := syncDir(fsys, path.Dir(dir)); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package fs
+
+import (
+	"os"
+)
+
+func flock(file *os.File, exclusive, wait bool) error {
+	return LockFileNotSupported
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 	"io/fs"
+	"os"
 )
 
 var MkDirNotSupported = errors.New("fs does not support mkdir")
@@ -11,6 +12,37 @@ var RemoveNotSupported = errors.New("fs does not support remove")
 var FileOpenNotSupported = errors.New("fs does not support OpenFile")
 var WriteableFileNotSupported = errors.New("fs file does not write")
 var RenameFileNotSupported = errors.New("fs does not support rename")
+var WriteNotSupported = errors.New("fs does not support write")
+var SyncDirNotSupported = errors.New("fs does not support directory sync")
+var LockFileNotSupported = errors.New("fs does not support file locks")
+
+// NotDurable is returned, if a change has already been applied and is visible, but flushing its directory failed,
+// so that the change may still be lost on power failure. Applying the change again is not required.
+var NotDurable = errors.New("change is not durable")
+
+// notDurable wraps the cause of a failed flush, so that the error matches both, NotDurable and the cause.
+func notDurable(err error) error {
+	return notDurableError{err: err}
+}
+
+type notDurableError struct {
+	err error
+}
+
+func (e notDurableError) Error() string {
+	return NotDurable.Error() + ": " + e.err.Error()
+}
+
+func (e notDurableError) Is(target error) bool {
+	return target == NotDurable
+}
+
+func (e notDurableError) Unwrap() error {
+	return e.err
+}
+
+// Locked is returned, if a lock is held by another process and waiting has not been requested.
+var Locked = errors.New("locked by another process")
 
 type RenameFileFS interface {
 	fs.FS
@@ -44,6 +76,26 @@ type MakeDirFileFS interface {
 	MkdirAll(name string) error
 }
 
+// SyncDirFS is the interface implemented by a file system, which requires an explicit sync of a directory, so that
+// renames, creations and removals of its entries survive a power loss, like an fsync of the directory on POSIX.
+type SyncDirFS interface {
+	fs.FS
+
+	// SyncDir flushes the entries of the named directory.
+	SyncDir(name string) error
+}
+
+// LockFileFS is the interface implemented by a file system, which supports advisory file locks to coordinate
+// multiple processes, like flock on POSIX. Locks are also exclusive between multiple calls of the same process.
+type LockFileFS interface {
+	fs.FS
+
+	// LockFile acquires a lock of the named file, which is created if required. An exclusive lock excludes
+	// any other lock, a shared lock only excludes exclusive locks. If wait is false, Locked is returned instead
+	// of blocking. Closing the returned Closer releases the lock.
+	LockFile(name string, exclusive, wait bool) (io.Closer, error)
+}
+
 type OpenFileFS interface {
 	fs.FS
 
@@ -79,15 +131,28 @@ func Remove(fsys fs.FS, name string) error {
 	return RemoveNotSupported
 }
 
-// OpenFile tries open a file using posix style.
+// OpenFile tries open a file using posix style. A filesystem without OpenFile support can still be opened read-only.
 func OpenFile(fsys fs.FS, name string, flag int, perm fs.FileMode) (fs.File, error) {
 	if fsys, ok := fsys.(OpenFileFS); ok {
 		return fsys.OpenFile(name, flag, perm)
 	}
 
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		return fsys.Open(name)
+	}
+
 	return nil, FileOpenNotSupported
 }
 
+// Write tries to write the named file transactionally.
+func Write(fsys fs.FS, name string, w func(w io.Writer) error) error {
+	if fsys, ok := fsys.(WriteFS); ok {
+		return fsys.Write(name, w)
+	}
+
+	return WriteNotSupported
+}
+
 func Rename(fsys fs.FS, oldpath, newpath string) error {
 	if fsys, ok := fsys.(RenameFileFS); ok {
 		return fsys.Rename(oldpath, newpath)
@@ -95,3 +160,21 @@ func Rename(fsys fs.FS, oldpath, newpath string) error {
 
 	return RenameFileNotSupported
 }
+
+// SyncDir tries to flush the entries of the named directory.
+func SyncDir(fsys fs.FS, name string) error {
+	if fsys, ok := fsys.(SyncDirFS); ok {
+		return fsys.SyncDir(name)
+	}
+
+	return SyncDirNotSupported
+}
+
+// LockFile tries to acquire an advisory lock of the named file.
+func LockFile(fsys fs.FS, name string, exclusive, wait bool) (io.Closer, error) {
+	if fsys, ok := fsys.(LockFileFS); ok {
+		return fsys.LockFile(name, exclusive, wait)
+	}
+
+	return nil, LockFileNotSupported
+}
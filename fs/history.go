@@ -0,0 +1,224 @@
+package fs
+
+import (
+	"errors"
+	"github.com/golangee/repository"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	revisionExt  = ".rev"
+	tombstoneExt = ".tomb"
+)
+
+// revision is a hidden sibling file of an entity, which encodes the time of the save or delete:
+//
+//	<dir>/.<base>.<unix nano>.rev
+//	<dir>/.<base>.<unix nano>.tomb
+type revision struct {
+	name    string
+	savedAt time.Time
+	deleted bool
+}
+
+// revisionName returns the sibling file name of the revision.
+func revisionName(name string, savedAt time.Time, deleted bool) string {
+	ext := revisionExt
+	if deleted {
+		ext = tombstoneExt
+	}
+
+	dir, base := path.Split(name)
+
+	return dir + "." + base + "." + strconv.FormatInt(savedAt.UnixNano(), 10) + ext
+}
+
+// revisions returns the revisions of the named entity ordered from the oldest to the newest.
+func (r *Repository[T, ID]) revisions(name string) ([]revision, error) {
+	dir, base := path.Split(name)
+	entries, err := fs.ReadDir(r.blobs.fs, path.Clean(dir))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	prefix := "." + base + "."
+	var res []revision
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+
+		rev := revision{name: dir + e.Name()}
+		stamp := strings.TrimPrefix(e.Name(), prefix)
+		switch {
+		case strings.HasSuffix(stamp, revisionExt):
+			stamp = strings.TrimSuffix(stamp, revisionExt)
+		case strings.HasSuffix(stamp, tombstoneExt):
+			stamp = strings.TrimSuffix(stamp, tombstoneExt)
+			rev.deleted = true
+		default:
+			continue // e.g. a temporary file
+		}
+
+		nanos, err := strconv.ParseInt(stamp, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		rev.savedAt = time.Unix(0, nanos)
+		res = append(res, rev)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].savedAt.Before(res[j].savedAt)
+	})
+
+	return res, nil
+}
+
+// recordSave writes a revision of the entity and returns its name. The caller must hold the write lock of the name.
+func (r *Repository[T, ID]) recordSave(name string, buf []byte) (string, error) {
+	revName := revisionName(name, r.opts.now(), false)
+	if err := mkdirAllDurable(r.blobs.fs, path.Dir(revName)); err != nil {
+		return "", err
+	}
+
+	if err := replaceFile(r.blobs.fs, revName, buf); err != nil {
+		return "", err
+	}
+
+	return revName, nil
+}
+
+// recordDelete writes a tombstone revision, unless the entity is unknown or already deleted. The caller must hold
+// the write lock of the name.
+func (r *Repository[T, ID]) recordDelete(name string) error {
+	revs, err := r.revisions(name)
+	if err != nil {
+		return err
+	}
+
+	if len(revs) == 0 || revs[len(revs)-1].deleted {
+		return nil
+	}
+
+	if err := replaceFile(r.blobs.fs, revisionName(name, r.opts.now(), true), nil); err != nil {
+		return err
+	}
+
+	return r.prune(name)
+}
+
+// prune removes the oldest revisions, which exceed the configured limits. The newest revision is always kept.
+// The caller must hold the write lock of the name.
+func (r *Repository[T, ID]) prune(name string) error {
+	revs, err := r.revisions(name)
+	if err != nil {
+		return err
+	}
+
+	drop := 0
+	if n := r.opts.revisions; n > 0 && len(revs) > n+1 {
+		drop = len(revs) - n - 1
+	}
+
+	if r.opts.historyRetention > 0 {
+		deadline := r.opts.now().Add(-r.opts.historyRetention)
+		for drop < len(revs)-1 && revs[drop].savedAt.Before(deadline) {
+			drop++
+		}
+	}
+
+	for _, rev := range revs[:drop] {
+		if err := Remove(r.blobs.fs, rev.name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// History returns the recorded revisions of the entity, ordered from the oldest to the current one.
+// This requires WithHistory, otherwise the history is always empty.
+func (r *Repository[T, ID]) History(id ID) ([]repository.Revision[T], error) {
+	name, err := r.name(id)
+	if err != nil {
+		return nil, err
+	}
+
+	revs, err := r.revisions(name)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]repository.Revision[T], 0, len(revs))
+	for _, rev := range revs {
+		v := repository.Revision[T]{SavedAt: rev.savedAt, Deleted: rev.deleted}
+		if !rev.deleted {
+			if v.Entity, err = r.readRevision(rev); err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue // pruned concurrently
+				}
+
+				return nil, err
+			}
+		}
+
+		res = append(res, v)
+	}
+
+	return res, nil
+}
+
+// FindByIDAt returns the entity as it has been at the given point in time. It fails with an EntityNotFoundError,
+// if the entity did not exist at that time or if the according revision has already been discarded.
+func (r *Repository[T, ID]) FindByIDAt(id ID, at time.Time) (T, error) {
+	var zero T
+	name, err := r.name(id)
+	if err != nil {
+		return zero, err
+	}
+
+	revs, err := r.revisions(name)
+	if err != nil {
+		return zero, err
+	}
+
+	for i := len(revs) - 1; i >= 0; i-- {
+		if revs[i].savedAt.After(at) {
+			continue
+		}
+
+		if revs[i].deleted {
+			break
+		}
+
+		entity, err := r.readRevision(revs[i])
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+
+		return entity, err
+	}
+
+	return zero, repository.EntityNotFoundError{ID: id}
+}
+
+func (r *Repository[T, ID]) readRevision(rev revision) (T, error) {
+	buf, err := fs.ReadFile(r.blobs.fs, rev.name)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return r.decode(buf)
+}
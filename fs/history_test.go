@@ -0,0 +1,88 @@
+package fs
+
+import (
+	"errors"
+	"github.com/golangee/repository"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRepository_History(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	repo := must(NewRepository[string, int](Dir(t.TempDir()), WithHistory(2, 0), WithClock(clock.Now)))
+
+	for _, v := range []string{"a", "b", "c", "d"} {
+		must("", repo.Save(1, v))
+		clock.Advance(time.Minute)
+	}
+
+	must("", repo.DeleteByID(1))
+
+	history := must(repo.History(1))
+	if len(history) != 3 || history[0].Entity != "c" || history[1].Entity != "d" || !history[2].Deleted {
+		t.Fatalf("unexpected history %v", history)
+	}
+
+	if n := must(repo.Count()); n != 0 {
+		t.Fatalf("revisions must not be visible but got %v entries", n)
+	}
+
+	if v := must(repo.FindByIDAt(1, time.Unix(1000+3*60+1, 0))); v != "d" {
+		t.Fatalf("unexpected entity %v", v)
+	}
+
+	if _, err := repo.FindByIDAt(1, clock.Now()); !errors.As(err, &repository.EntityNotFoundError{}) {
+		t.Fatalf("expected deleted entity but got %v", err)
+	}
+
+	if _, err := repo.FindByIDAt(1, time.Unix(1000, 0)); !errors.As(err, &repository.EntityNotFoundError{}) {
+		t.Fatalf("expected pruned revision but got %v", err)
+	}
+}
+
+func TestRepository_HistoryConcurrent(t *testing.T) {
+	var ticks int64
+	now := func() time.Time {
+		return time.Unix(0, atomic.AddInt64(&ticks, 1))
+	}
+
+	repo := must(NewRepository[string, int](Dir(t.TempDir()), WithHistory(1, 0), WithClock(now)))
+
+	for round := 0; round < 10; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					if (i+j)%5 == 0 {
+						must("", repo.DeleteByID(1))
+					} else {
+						must("", repo.Save(1, strconv.Itoa(i*100+j)))
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		// the newest revision must always describe the current entity
+		history := must(repo.History(1))
+		if len(history) == 0 {
+			t.Fatal("expected history")
+		}
+
+		latest := history[len(history)-1]
+		v, err := repo.FindByID(1)
+		switch {
+		case err != nil && !latest.Deleted:
+			t.Fatalf("expected tombstone but got %v: %v", latest.Entity, err)
+		case err == nil && (latest.Deleted || latest.Entity != v):
+			t.Fatalf("expected revision %v but got %v", v, latest)
+		}
+	}
+}
@@ -1,32 +1,465 @@
 package fs
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/internal/lock"
 	"github.com/golangee/repository/internal/reflect"
+	"github.com/golangee/repository/iter"
+	"github.com/golangee/repository/schema"
+	"io"
 	"io/fs"
+	"path"
+	"strings"
 )
 
-// Repository is a generic CrudRepository using json marshalling to serialize into the filesystem.
+const entityExt = ".json"
 
+// Repository is a generic CrudRepository using json marshalling to serialize into the filesystem.
+// Each entity is stored as a blob, using the fanout structure described by BlobRepository:
+//
+//	hex(sha256(json(id)))[0])/hex(json(id))".json"
+//
+// Because the file name contains the encoded ID, an ID must not be larger than 127 bytes in its json representation.
+// Each operation is atomic, however SaveAll is not transactional and stops at the first error.
 type Repository[T any, ID comparable] struct {
 	factory   func() T
 	isPtrType bool
-	fs        fs.FS
+	blobs     *BlobRepository[string]
+	opts      options
+	locks     lock.Table[ID]
 }
 
-func NewRepository[T any, ID comparable](fs fs.FS) (*Repository[T, ID], error) {
+func NewRepository[T any, ID comparable](fs fs.FS, opts ...Option) (*Repository[T, ID], error) {
 	fac, ptr := reflect.Constructor[T]()
 
-	for prefix := byte(0); prefix < 0xff; prefix++ {
-		if err := MkdirAll(fs, hex.EncodeToString([]byte{prefix})); err != nil {
-			return nil, fmt.Errorf("cannot initialize fanout: %w", err)
-		}
+	blobs, err := NewBlobRepository[string](fs, opts...)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Repository[T, ID]{
 		factory:   fac,
 		isPtrType: ptr,
-		fs:        fs,
+		blobs:     blobs,
+		opts:      newOptions(opts),
 	}, nil
 }
+
+// Close releases the locks of the repository, see WithLocking. The repository must not be used afterwards.
+func (r *Repository[T, ID]) Close() error {
+	return r.blobs.Close()
+}
+
+// Count returns the amount of entities. Like FindAll, it ignores foreign files, whose names are not valid entity
+// names.
+func (r *Repository[T, ID]) Count() (int64, error) {
+	names, err := r.blobs.FindAll(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	count := int64(0)
+	err = iter.Walk(names, func(name string) error {
+		if _, ok := r.id(name); ok {
+			count++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *Repository[T, ID]) DeleteByID(id ID) error {
+	name, err := r.name(id)
+	if err != nil {
+		return err
+	}
+
+	if !r.opts.history {
+		return r.blobs.Delete(context.Background(), name)
+	}
+
+	if r.opts.readOnly {
+		return ReadOnly
+	}
+
+	r.blobs.maybePurge(context.Background())
+
+	// the deletion and its tombstone are applied under the same lock, so that concurrent saves cannot interleave
+	return r.blobs.locked(name, func() error {
+		removed, err := r.blobs.remove(name)
+		if err != nil || !removed {
+			return err
+		}
+
+		r.blobs.notify(repository.Deleted, name)
+
+		return r.recordDelete(name)
+	})
+}
+
+// DeleteAll removes all entities. If the history is enabled, a deletion is recorded for each entity, which is not
+// transactional with respect to concurrent saves.
+func (r *Repository[T, ID]) DeleteAll() error {
+	if !r.opts.history {
+		return r.blobs.DeleteAll(context.Background())
+	}
+
+	it, err := r.blobs.FindAll(context.Background())
+	if err != nil {
+		return err
+	}
+
+	names, err := iter.Collect(it)
+	if err != nil {
+		return err
+	}
+
+	if err := r.blobs.DeleteAll(context.Background()); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if _, ok := r.id(name); ok {
+			if err := r.blobs.locked(name, func() error { return r.recordDelete(name) }); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository[T, ID]) Save(id ID, entity T) error {
+	name, err := r.name(id)
+	if err != nil {
+		return err
+	}
+
+	if r.opts.readOnly {
+		return ReadOnly
+	}
+
+	buf, err := r.marshal(entity)
+	if err != nil {
+		return err
+	}
+
+	if !r.opts.history {
+		return r.write(name, buf)
+	}
+
+	// record the revision first, so that the history always contains the current entity. All steps are applied
+	// under the same lock, so that concurrent saves and deletes cannot interleave their revisions.
+	return r.blobs.locked(name, func() error {
+		revName, err := r.recordSave(name, buf)
+		if err != nil {
+			return err
+		}
+
+		if err := r.blobs.replace(name, buf); err != nil {
+			_ = Remove(r.blobs.fs, revName)
+			return err
+		}
+
+		return r.prune(name)
+	})
+}
+
+func (r *Repository[T, ID]) write(name string, buf []byte) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := r.blobs.Write(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(buf); err != nil {
+		cancel() // discard partial write
+		_ = w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+func (r *Repository[T, ID]) SaveAll(f func() (ID, T, error)) error {
+	for {
+		id, entity, err := f()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := r.Save(id, entity); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Repository[T, ID]) FindByID(id ID) (T, error) {
+	var entity T
+	name, err := r.name(id)
+	if err != nil {
+		return entity, err
+	}
+
+	buf, err := r.read(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return entity, repository.EntityNotFoundError{ID: id}
+		}
+
+		return entity, err
+	}
+
+	return r.decode(buf)
+}
+
+// FindAll invokes the callback for each entry and transfers the ownership. Entities which are deleted
+// concurrently are skipped.
+func (r *Repository[T, ID]) FindAll(f func(id ID, entity T) error) error {
+	names, err := r.blobs.FindAll(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return iter.Walk(names, func(name string) error {
+		id, ok := r.id(name)
+		if !ok {
+			return nil // not one of our files
+		}
+
+		buf, err := r.read(name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		entity, err := r.decode(buf)
+		if err != nil {
+			return err
+		}
+
+		return f(id, entity)
+	})
+}
+
+func (r *Repository[T, ID]) read(name string) ([]byte, error) {
+	reader, err := r.blobs.Read(context.Background(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// name calculates the fanout file name of the id.
+func (r *Repository[T, ID]) name(id ID) (string, error) {
+	buf, err := json.Marshal(id)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode id: %w", err)
+	}
+
+	sum := sha256.Sum256(buf)
+	name := hex.EncodeToString(sum[:1]) + "/" + hex.EncodeToString(buf) + entityExt
+	if !ValidName(name) {
+		return "", InvalidFilename
+	}
+
+	return name, nil
+}
+
+// id decodes the name created by name.
+func (r *Repository[T, ID]) id(name string) (ID, bool) {
+	var id ID
+	base := path.Base(name)
+	if !strings.HasSuffix(base, entityExt) {
+		return id, false
+	}
+
+	buf, err := hex.DecodeString(strings.TrimSuffix(base, entityExt))
+	if err != nil {
+		return id, false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	if err := dec.Decode(&id); err != nil {
+		return id, false
+	}
+
+	return id, true
+}
+
+// RestoreDeleted restores the most recently deleted version of the entity. This requires WithSoftDelete.
+// It fails with an EntityNotFoundError, if no such deleted entity exists and with fs.ErrExist, if the entity has
+// been saved again in the meantime.
+func (r *Repository[T, ID]) RestoreDeleted(id ID) error {
+	name, err := r.name(id)
+	if err != nil {
+		return err
+	}
+
+	if !r.opts.history {
+		return r.notFound(id, r.blobs.Restore(context.Background(), name))
+	}
+
+	if r.opts.readOnly {
+		return ReadOnly
+	}
+
+	return r.blobs.locked(name, func() error {
+		if err := r.blobs.restore(name); err != nil {
+			return r.notFound(id, err)
+		}
+
+		buf, err := fs.ReadFile(r.blobs.fs, name)
+		if err != nil {
+			return err
+		}
+
+		if _, err := r.recordSave(name, buf); err != nil {
+			return err
+		}
+
+		return r.prune(name)
+	})
+}
+
+// notFound translates a missing file into an EntityNotFoundError.
+func (r *Repository[T, ID]) notFound(id ID, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return repository.EntityNotFoundError{ID: id}
+	}
+
+	return err
+}
+
+// ListDeleted returns all deleted entities which can still be restored, ordered by deletion time.
+func (r *Repository[T, ID]) ListDeleted() ([]repository.Trashed[ID], error) {
+	trashed, err := r.blobs.ListDeleted(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]repository.Trashed[ID], 0, len(trashed))
+	for _, t := range trashed {
+		if id, ok := r.id(t.ID); ok {
+			res = append(res, repository.Trashed[ID]{ID: id, DeletedAt: t.DeletedAt})
+		}
+	}
+
+	return res, nil
+}
+
+// PurgeDeleted removes all deleted entities, which are older than the retention period and returns the amount of
+// purged entities.
+func (r *Repository[T, ID]) PurgeDeleted() (int, error) {
+	return r.blobs.PurgeDeleted(context.Background())
+}
+
+// Upgrade rewrites all entities which have been saved with an older schema version into the current version.
+// Each entity is locked while being rewritten, so Upgrade can be run in the background while the repository is used.
+// Returns the amount of rewritten entities.
+func (r *Repository[T, ID]) Upgrade(ctx context.Context) (int, error) {
+	names, err := r.blobs.FindAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	current := r.opts.schema.Current()
+	count := 0
+	err = iter.Walk(names, func(name string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, ok := r.id(name); !ok {
+			return nil
+		}
+
+		upgraded, err := r.blobs.update(name, func(buf []byte) ([]byte, error) {
+			if version, _ := schema.Decode(buf); version == current {
+				return nil, nil
+			}
+
+			entity, err := r.decode(buf)
+			if err != nil {
+				return nil, err
+			}
+
+			return r.marshal(entity)
+		})
+
+		if upgraded {
+			count++
+		}
+
+		return err
+	})
+
+	return count, err
+}
+
+func (r *Repository[T, ID]) marshal(entity T) ([]byte, error) {
+	buf, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.opts.schema == nil {
+		return buf, nil
+	}
+
+	return schema.Encode(r.opts.schema.Current(), buf)
+}
+
+// decode unwraps the schema envelope, upcasts the document to the current version, if required, and unmarshals it.
+func (r *Repository[T, ID]) decode(buf []byte) (T, error) {
+	version, doc := schema.Decode(buf)
+	if version != r.opts.schema.Current() {
+		var err error
+		if doc, err = r.opts.schema.Upcast(version, doc); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+
+	return r.unmarshal(doc)
+}
+
+func (r *Repository[T, ID]) unmarshal(buf []byte) (T, error) {
+	entity := r.factory()
+	if r.isPtrType {
+		if err := json.Unmarshal(buf, entity); err != nil {
+			return entity, err
+		}
+	} else {
+		if err := json.Unmarshal(buf, &entity); err != nil {
+			return entity, err
+		}
+	}
+
+	return entity, nil
+}
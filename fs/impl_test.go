@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/golangee/repository/internal/test"
+	"io/fs"
+	"testing"
+)
+
+func TestRepository(t *testing.T) {
+	var a test.CrudTestRepository[test.A, string]
+	a = must(NewRepository[test.A, string](Dir(t.TempDir())))
+	test.Test(t, test.CreateTestSet1(), a)
+
+	var a2 test.CrudTestRepository[test.B, test.A]
+	a2 = must(NewRepository[test.B, test.A](Dir(t.TempDir())))
+	test.Test(t, test.CreateTestSet2(), a2)
+
+	var a3 test.CrudTestRepository[*test.B, int]
+	a3 = must(NewRepository[*test.B, int](Dir(t.TempDir())))
+	test.Test(t, test.CreateTestSet3(), a3)
+}
+
+func TestRepository_layout(t *testing.T) {
+	fsys := NewMemFS()
+	repo := must(NewRepository[string, int](fsys))
+	must("", repo.Save(42, "hello"))
+
+	// 42 is encoded as json and hex, prefixed by the first byte of its sha256 sum
+	sum := sha256.Sum256([]byte("42"))
+	name := hex.EncodeToString(sum[:1]) + "/" + hex.EncodeToString([]byte("42")) + entityExt
+	if buf := must(fs.ReadFile(fsys, name)); string(buf) != `"hello"` {
+		t.Fatalf("expected entity at %v but got %v", name, string(buf))
+	}
+
+	// foreign files are not entities
+	w := must(repo.blobs.Write(context.Background(), "00/foreign.txt"))
+	must(w.Write([]byte("foreign")))
+	must("", w.Close())
+
+	count := 0
+	must("", repo.FindAll(func(id int, entity string) error {
+		if id != 42 || entity != "hello" {
+			t.Fatalf("unexpected entity %v %v", id, entity)
+		}
+
+		count++
+		return nil
+	}))
+
+	if count != 1 {
+		t.Fatalf("expected 1 entity but got %v", count)
+	}
+
+	if n := must(repo.Count()); n != 1 {
+		t.Fatalf("expected Count to ignore foreign files like FindAll but got %v", n)
+	}
+}
@@ -1,47 +1,178 @@
 package fs
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// SymlinkNotFollowed is returned by a filesystem opened with DirNoFollow, if a path contains a symbolic link.
+var SymlinkNotFollowed = errors.New("symbolic link not followed")
+
 type dirFS struct {
-	root string
+	root      string
+	noFollow  bool
+	noDirSync bool
+}
+
+// DirOption configures a filesystem returned by Dir or DirNoFollow.
+type DirOption func(*dirFS)
+
+// DirSync enables or disables the fsync of directories by SyncDir, which is required on POSIX to make renames
+// durable. Disable it for filesystems which do not support it. Defaults to enabled, except on Windows.
+func DirSync(enabled bool) DirOption {
+	return func(l *dirFS) {
+		l.noDirSync = !enabled
+	}
+}
+
+func newDirFS(path string, noFollow bool, opts []DirOption) dirFS {
+	l := dirFS{root: path, noFollow: noFollow, noDirSync: runtime.GOOS == "windows"}
+	for _, opt := range opts {
+		opt(&l)
+	}
+
+	return l
 }
 
 // Dir opens a read and writeable filesystem which implements a WriteFile method with transactional semantics.
-func Dir(path string) fs.FS {
-	return dirFS{root: path}
+// All names must be valid in the sense of fs.ValidPath, otherwise fs.ErrInvalid is returned. Symbolic links
+// inside the root are followed and may therefore point outside of the root, see also DirNoFollow.
+func Dir(path string, opts ...DirOption) fs.FS {
+	return newDirFS(path, false, opts)
 }
 
-func (l dirFS) Open(name string) (fs.File, error) {
+// DirNoFollow is like Dir but refuses to follow any symbolic link below the root with SymlinkNotFollowed.
+// On Linux, each path is resolved component by component relative to an opened directory (openat), so that
+// even concurrent replacements by symbolic links cannot escape the root. On other platforms, the components are
+// checked before each operation, which is not free of races.
+func DirNoFollow(path string, opts ...DirOption) fs.FS {
+	return newDirFS(path, true, opts)
+}
+
+// join validates the name and returns the native path below the root.
+func (l dirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) || (filepath.Separator != '/' && strings.ContainsAny(name, `\:`)) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
 	// windows also accepts the slashes from fs.FS
-	return os.Open(filepath.Join(l.root, path.Clean(name)))
+	return filepath.Join(l.root, filepath.FromSlash(name)), nil
+}
+
+func (l dirFS) Open(name string) (fs.File, error) {
+	return l.OpenFile(name, os.O_RDONLY, 0)
 }
 
 func (l dirFS) Remove(name string) error {
-	// windows also accepts the slashes from fs.FS
-	return os.Remove(filepath.Join(l.root, path.Clean(name)))
+	full, err := l.join("remove", name)
+	if err != nil {
+		return err
+	}
+
+	if l.noFollow {
+		return l.removeNoFollow(name)
+	}
+
+	return os.Remove(full)
 }
 
 func (l dirFS) Rename(oldpath, newpath string) error {
-	// windows also accepts the slashes from fs.FS
-	return os.Rename(filepath.Join(l.root, path.Clean(oldpath)), filepath.Join(l.root, path.Clean(newpath)))
+	oldFull, err := l.join("rename", oldpath)
+	if err != nil {
+		return err
+	}
+
+	newFull, err := l.join("rename", newpath)
+	if err != nil {
+		return err
+	}
+
+	if l.noFollow {
+		return l.renameNoFollow(oldpath, newpath)
+	}
+
+	return os.Rename(oldFull, newFull)
 }
 
 func (l dirFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
-	// windows also accepts the slashes from fs.FS
-	return os.OpenFile(filepath.Join(l.root, path.Clean(name)), flag, perm)
+	full, err := l.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.noFollow {
+		file, err := l.openNoFollow(name, flag, perm)
+		if err != nil {
+			return nil, err // avoid a typed nil
+		}
+
+		return file, nil
+	}
+
+	return os.OpenFile(full, flag, perm)
 }
 
 func (l dirFS) MkdirAll(name string) error {
-	// windows also accepts the slashes from fs.FS
-	return os.MkdirAll(filepath.Join(l.root, name), 0700) // just allow owner read/write/list and not the world
+	full, err := l.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	if l.noFollow {
+		return l.mkdirAllNoFollow(name)
+	}
+
+	return os.MkdirAll(full, 0700) // just allow owner read/write/list and not the world
+}
+
+// SyncDir performs a fsync on the named directory, so that preceding renames, creations and removals of its entries
+// survive a power loss. This is a no-op, if disabled by DirSync.
+func (l dirFS) SyncDir(name string) error {
+	if _, err := l.join("syncdir", name); err != nil {
+		return err
+	}
+
+	if l.noDirSync {
+		return nil
+	}
+
+	dir, err := l.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer dir.Close()
+
+	return dir.(*os.File).Sync()
+}
+
+// LockFile acquires an advisory lock using flock, which is only supported on unix like systems.
+func (l dirFS) LockFile(name string, exclusive, wait bool) (io.Closer, error) {
+	if _, err := l.join("lock", name); err != nil {
+		return nil, err
+	}
+
+	f, err := l.OpenFile(name, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	file := f.(*os.File)
+	if err := flock(file, exclusive, wait); err != nil {
+		_ = file.Close()
+		return nil, &fs.PathError{Op: "lock", Path: name, Err: err}
+	}
+
+	return file, nil
 }
 
 // WriteFile performs a transactional write, a fsync and an atomic posix rename.
@@ -53,32 +184,44 @@ func (l dirFS) WriteFile(name string, data []byte) (err error) {
 	})
 }
 
-// Write performs a transactional write, a fsync and an atomic posix rename.
+// Write performs a transactional write, a fsync, an atomic posix rename and a fsync of the directory. If only the
+// latter fails, the file has already been replaced and NotDurable is returned.
 // Fails on windows, if destination file is still open, posix allows that kind of concurrency.
 func (l dirFS) Write(name string, w func(io.Writer) error) (err error) {
-	// windows also accepts the slashes from fs.FS
-	dst := filepath.Join(l.root, name)
-	tmp := dst + "." + strconv.FormatInt(time.Now().UnixMicro(), 10) + ".tmp"
+	if _, err := l.join("write", name); err != nil {
+		return err
+	}
+
+	tmp := name + "." + strconv.FormatInt(time.Now().UnixMicro(), 10) + ".tmp"
 
 	// just allow owner read/write and not the world
-	file, e := os.OpenFile(tmp, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0600)
+	f, e := l.OpenFile(tmp, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0600)
 	if e != nil {
 		return e
 	}
 
+	file := f.(*os.File)
+
 	defer func() {
 		e := file.Close() // delayed write may fail (e.g. fuse, nfs, etc)
-		if e != nil {
-			if err == nil {
-				err = e
-			}
+		if err == nil {
+			err = e
+		}
 
+		if err != nil {
+			_ = l.Remove(tmp) // never commit a partial write
 			return
 		}
 
 		// posix atomic replace
-		if e := os.Rename(tmp, dst); e != nil && err == nil {
+		if e := l.Rename(tmp, name); e != nil {
+			_ = l.Remove(tmp)
 			err = e
+			return
+		}
+
+		if e := l.SyncDir(path.Dir(name)); e != nil {
+			err = notDurable(fmt.Errorf("cannot sync directory of %s: %w", name, e))
 		}
 	}()
 
@@ -0,0 +1,193 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// sandbox returns a root directory and a sibling directory outside of it, containing a single secret file.
+func sandbox(t *testing.T) (root, outside string) {
+	base := t.TempDir()
+	root = filepath.Join(base, "root")
+	outside = filepath.Join(base, "outside")
+	must("", os.Mkdir(root, 0700))
+	must("", os.Mkdir(outside, 0700))
+	must("", os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0600))
+
+	return root, outside
+}
+
+// assertUntouched fails, if the outside directory does not only contain the unmodified secret.
+func assertUntouched(t *testing.T, outside string) {
+	t.Helper()
+
+	entries := must(os.ReadDir(outside))
+	if len(entries) != 1 || entries[0].Name() != "secret" {
+		t.Fatalf("outside has been modified: %v", entries)
+	}
+
+	if buf := must(os.ReadFile(filepath.Join(outside, "secret"))); string(buf) != "secret" {
+		t.Fatalf("secret has been modified: %q", buf)
+	}
+}
+
+// fsMethods invokes each method of a dirFS with the given name.
+func fsMethods(fsys fs.FS) map[string]func(name string) error {
+	write := func(w io.Writer) error {
+		_, err := io.WriteString(w, "evil")
+		return err
+	}
+
+	return map[string]func(name string) error{
+		"Open": func(name string) error {
+			_, err := fsys.Open(name)
+			return err
+		},
+		"OpenFile": func(name string) error {
+			_, err := OpenFile(fsys, name, os.O_CREATE|os.O_WRONLY, 0600)
+			return err
+		},
+		"Stat": func(name string) error {
+			_, err := fs.Stat(fsys, name)
+			return err
+		},
+		"ReadDir": func(name string) error {
+			_, err := fs.ReadDir(fsys, name)
+			return err
+		},
+		"Remove": func(name string) error {
+			return Remove(fsys, name)
+		},
+		"RenameFrom": func(name string) error {
+			return Rename(fsys, name, "target")
+		},
+		"RenameTo": func(name string) error {
+			return Rename(fsys, "source", name)
+		},
+		"MkdirAll": func(name string) error {
+			return MkdirAll(fsys, name)
+		},
+		"Write": func(name string) error {
+			return Write(fsys, name, write)
+		},
+		"WriteFile": func(name string) error {
+			return fsys.(interface {
+				WriteFile(name string, data []byte) error
+			}).WriteFile(name, []byte("evil"))
+		},
+	}
+}
+
+func TestDir_InvalidPath(t *testing.T) {
+	root, outside := sandbox(t)
+	for mode, fsys := range map[string]fs.FS{"follow": Dir(root), "nofollow": DirNoFollow(root)} {
+		must("", os.WriteFile(filepath.Join(root, "source"), []byte("source"), 0600))
+		for method, f := range fsMethods(fsys) {
+			for _, name := range []string{"../outside/secret", "../outside/x", "/x", "a/../../outside", "", "./x", "a//b", "a/"} {
+				if err := f(name); !errors.Is(err, fs.ErrInvalid) {
+					t.Fatalf("%s %s(%q): expected invalid path but got %v", mode, method, name, err)
+				}
+			}
+		}
+	}
+
+	assertUntouched(t, outside)
+	if entries := must(os.ReadDir(root)); len(entries) != 1 {
+		t.Fatalf("unexpected root entries: %v", entries)
+	}
+}
+
+func TestDirNoFollow_Symlink(t *testing.T) {
+	root, outside := sandbox(t)
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("symbolic links not supported: %v", err)
+	}
+
+	must("", os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "flink")))
+	must("", os.WriteFile(filepath.Join(root, "source"), []byte("source"), 0600))
+
+	// the default mode follows symbolic links
+	if buf := must(fs.ReadFile(Dir(root), "link/secret")); string(buf) != "secret" {
+		t.Fatalf("unexpected secret %q", buf)
+	}
+
+	fsys := DirNoFollow(root)
+	for method, f := range fsMethods(fsys) {
+		names := []string{"link/secret", "link/x", "link/sub/x"}
+		switch method {
+		case "Remove", "RenameFrom", "RenameTo", "Write", "WriteFile":
+			// removing or replacing the link itself is harmless
+		default:
+			names = append(names, "link", "flink")
+		}
+
+		for _, name := range names {
+			if err := f(name); !errors.Is(err, SymlinkNotFollowed) {
+				t.Fatalf("%s(%q): expected symlink error but got %v", method, name, err)
+			}
+		}
+	}
+
+	assertUntouched(t, outside)
+
+	// replacing the link does not touch its target
+	must("", Rename(fsys, "source", "flink"))
+	must("", Remove(fsys, "link"))
+	assertUntouched(t, outside)
+
+	if buf := must(fs.ReadFile(fsys, "flink")); string(buf) != "source" {
+		t.Fatalf("unexpected content %q", buf)
+	}
+}
+
+func TestDir_WriteFailure(t *testing.T) {
+	root := t.TempDir()
+	fsys := Dir(root)
+	must("", Write(fsys, "a", func(w io.Writer) error {
+		_, err := io.WriteString(w, "old")
+		return err
+	}))
+
+	injected := errors.New("injected")
+	err := Write(fsys, "a", func(w io.Writer) error {
+		_, _ = io.WriteString(w, "partial")
+		return injected
+	})
+
+	if !errors.Is(err, injected) {
+		t.Fatalf("expected injected error but got %v", err)
+	}
+
+	if buf := must(fs.ReadFile(fsys, "a")); string(buf) != "old" {
+		t.Fatalf("expected old content but got %q", buf)
+	}
+
+	if entries := must(os.ReadDir(root)); len(entries) != 1 {
+		t.Fatalf("unexpected leftovers: %v", entries)
+	}
+}
+
+func TestDir_SyncDir(t *testing.T) {
+	root := t.TempDir()
+	for _, fsys := range []fs.FS{Dir(root), DirNoFollow(root), Dir(root, DirSync(false))} {
+		must("", MkdirAll(fsys, "a/b"))
+		must("", SyncDir(fsys, "a/b"))
+		must("", SyncDir(fsys, "."))
+
+		if err := SyncDir(fsys, "../a"); !errors.Is(err, fs.ErrInvalid) {
+			t.Fatalf("expected invalid path but got %v", err)
+		}
+	}
+
+	if err := SyncDir(Dir(root), "missing"); !errors.Is(err, fs.ErrNotExist) && runtime.GOOS != "windows" {
+		t.Fatalf("expected missing directory but got %v", err)
+	}
+
+	// disabled syncs are not even checked
+	must("", SyncDir(Dir(root, DirSync(false)), "missing"))
+}
@@ -0,0 +1,161 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository"
+	"path"
+	"sync"
+	"time"
+)
+
+// lockPollInterval is the delay between attempts to acquire a file lock held by another process.
+const lockPollInterval = 10 * time.Millisecond
+
+// Lock acquires an exclusive, advisory lock of the id, waiting until ctx is done. The lock only coordinates the
+// callers of this instance, e.g. to serialize a read-modify-write cycle, and is independent from the internal
+// locking of Read and Write, so holding it while reading or writing the same id does not deadlock.
+// If WithLocking(LockPerID) is enabled, the lock additionally holds an advisory file lock, so that it also
+// coordinates other repositories sharing the directory. Otherwise, other processes are not aware of it.
+func (r *BlobRepository[ID]) Lock(ctx context.Context, id ID) (repository.Unlock, error) {
+	if !ValidName(id) {
+		return nil, InvalidFilename
+	}
+
+	unlock, err := r.locks.Lock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.fileLock(ctx, string(id), true, 0, unlock)
+}
+
+// RLock acquires a shared, advisory lock of the id, see also Lock.
+func (r *BlobRepository[ID]) RLock(ctx context.Context, id ID) (repository.Unlock, error) {
+	if !ValidName(id) {
+		return nil, InvalidFilename
+	}
+
+	unlock, err := r.locks.RLock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.fileLock(ctx, string(id), false, 0, unlock)
+}
+
+// LockLease is like Lock but releases the lock automatically after the lease, so that a crashed or hanging caller
+// cannot block others forever. Unlocking an expired lock returns repository.LeaseExpired.
+func (r *BlobRepository[ID]) LockLease(ctx context.Context, id ID, lease time.Duration) (repository.Unlock, error) {
+	if !ValidName(id) {
+		return nil, InvalidFilename
+	}
+
+	unlock, err := r.locks.LockLease(ctx, id, lease)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.fileLock(ctx, string(id), true, lease, unlock)
+}
+
+// fileLock extends the acquired in-process lock of the named blob by an advisory file lock, if LockPerID is
+// enabled. Its lock file is distinct from the one used by Read and Write. Because a file lock cannot be awaited
+// with a context, it is polled until ctx is done. The file lock is released before the in-process lock or when
+// the lease expires.
+func (r *BlobRepository[ID]) fileLock(ctx context.Context, name string, exclusive bool, lease time.Duration, unlock repository.Unlock) (repository.Unlock, error) {
+	if r.opts.locking != LockPerID {
+		return unlock, nil
+	}
+
+	lockName := lockFileName(name, ".advisory")
+	if err := MkdirAll(r.fs, path.Dir(lockName)); err != nil {
+		_ = unlock()
+		return nil, err
+	}
+
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		file, err := LockFile(r.fs, lockName, exclusive, false)
+		if err == nil {
+			var once sync.Once
+			release := func() {
+				once.Do(func() { _ = file.Close() })
+			}
+
+			var timer *time.Timer
+			if lease > 0 {
+				timer = time.AfterFunc(lease, release)
+			}
+
+			return func() error {
+				if timer != nil {
+					timer.Stop()
+				}
+
+				release()
+
+				return unlock()
+			}, nil
+		}
+
+		if !errors.Is(err, Locked) {
+			_ = unlock()
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = unlock()
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Lock acquires an exclusive, advisory lock of the entity, see BlobRepository.Lock.
+func (r *Repository[T, ID]) Lock(ctx context.Context, id ID) (repository.Unlock, error) {
+	unlock, err := r.locks.Lock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.fileLock(ctx, id, true, 0, unlock)
+}
+
+// RLock acquires a shared, advisory lock of the entity, see BlobRepository.Lock.
+func (r *Repository[T, ID]) RLock(ctx context.Context, id ID) (repository.Unlock, error) {
+	unlock, err := r.locks.RLock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.fileLock(ctx, id, false, 0, unlock)
+}
+
+// LockLease acquires an exclusive, advisory lock of the entity with a lease, see BlobRepository.LockLease.
+func (r *Repository[T, ID]) LockLease(ctx context.Context, id ID, lease time.Duration) (repository.Unlock, error) {
+	unlock, err := r.locks.LockLease(ctx, id, lease)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.fileLock(ctx, id, true, lease, unlock)
+}
+
+// fileLock extends the in-process lock by the advisory file lock of the entity, if LockPerID is enabled.
+func (r *Repository[T, ID]) fileLock(ctx context.Context, id ID, exclusive bool, lease time.Duration, unlock repository.Unlock) (repository.Unlock, error) {
+	if r.opts.locking != LockPerID {
+		return unlock, nil
+	}
+
+	name, err := r.name(id)
+	if err != nil {
+		_ = unlock()
+		return nil, err
+	}
+
+	return r.blobs.fileLock(ctx, name, exclusive, lease, unlock)
+}
@@ -0,0 +1,265 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/internal/test"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLockFileSystems returns all filesystems supporting file locks on this platform.
+func testLockFileSystems(t *testing.T) map[string]fs.FS {
+	res := map[string]fs.FS{"mem": NewMemFS()}
+	fsys := Dir(t.TempDir())
+	if lock, err := LockFile(fsys, ".probe", true, false); err == nil {
+		must("", lock.Close())
+		res["dir"] = fsys
+	}
+
+	return res
+}
+
+func TestBlobRepository_LockDirectory(t *testing.T) {
+	for name, fsys := range testLockFileSystems(t) {
+		fsys := fsys
+		t.Run(name, func(t *testing.T) {
+			a := must(NewBlobRepository[string](fsys, WithLocking(LockDirectory)))
+			if _, err := NewBlobRepository[string](fsys, WithLocking(LockDirectory)); !errors.Is(err, Locked) {
+				t.Fatalf("expected locked directory but got %v", err)
+			}
+
+			must("", a.Close())
+			must("", a.Close())
+
+			b := must(NewBlobRepository[string](fsys, WithLocking(LockDirectory)))
+			if n := must(b.Count(context.Background())); n != 0 {
+				t.Fatalf("expected lock file to be hidden but got %v blobs", n)
+			}
+
+			must("", b.Close())
+		})
+	}
+}
+
+func TestBlobRepository_LockPerID(t *testing.T) {
+	ctx := context.Background()
+	for name, fsys := range testLockFileSystems(t) {
+		fsys := fsys
+		t.Run(name, func(t *testing.T) {
+			// two instances behave like two processes, because their in-process mutexes are independent
+			repos := []*BlobRepository[string]{
+				must(NewBlobRepository[string](fsys, WithLocking(LockPerID))),
+				must(NewBlobRepository[string](fsys, WithLocking(LockPerID))),
+			}
+
+			must("", crashStep{id: "a/counter", data: []byte("0")}.apply(ctx, repos[0]))
+
+			// read-modify-write cycles of both instances are never lost
+			const rounds = 50
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				repo := repos[i%2]
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < rounds; j++ {
+						must(repo.update("a/counter", func(buf []byte) ([]byte, error) {
+							n, err := strconv.Atoi(string(buf))
+							return []byte(strconv.Itoa(n + 1)), err
+						}))
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			r := must(repos[1].Read(ctx, "a/counter"))
+			if buf := must(io.ReadAll(r)); string(buf) != strconv.Itoa(4*rounds) {
+				t.Fatalf("expected %v but got %s", 4*rounds, buf)
+			}
+
+			// an open reader of one instance blocks the commit of the other
+			committed := make(chan error)
+			w := must(repos[0].Write(ctx, "a/counter"))
+			must(w.Write([]byte("new")))
+			go func() {
+				committed <- w.Close()
+			}()
+
+			select {
+			case err := <-committed:
+				t.Fatalf("expected blocked commit but got %v", err)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			must("", r.Close())
+			must("", <-committed)
+
+			if n := must(repos[0].Count(ctx)); n != 1 {
+				t.Fatalf("expected lock files to be hidden but got %v blobs", n)
+			}
+
+			for _, repo := range repos {
+				repo.assertEmptyMutexes()
+				must("", repo.Close())
+			}
+		})
+	}
+}
+
+func TestBlobRepository_LockPerIDLongName(t *testing.T) {
+	ctx := context.Background()
+	for name, fsys := range testLockFileSystems(t) {
+		fsys := fsys
+		t.Run(name, func(t *testing.T) {
+			repo := must(NewBlobRepository[string](fsys, WithLocking(LockPerID)))
+
+			// the lock file of a valid name with a maximum length segment must not exceed NAME_MAX
+			id := "a/" + strings.Repeat("b", NAME_MAX)
+			must("", crashStep{id: id, data: []byte("hello")}.apply(ctx, repo))
+			if n := must(repo.Count(ctx)); n != 1 {
+				t.Fatalf("expected 1 blob but got %v", n)
+			}
+
+			must("", repo.Delete(ctx, id))
+			must("", repo.Close())
+		})
+	}
+}
+
+func TestBlobRepository_CloseConcurrent(t *testing.T) {
+	repo := must(NewBlobRepository[string](NewMemFS(), WithLocking(LockDirectory)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			must("", repo.Close())
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestBlobRepository_Locker(t *testing.T) {
+	repo := must(NewBlobRepository[string](NewMemFS()))
+	test.TestLocker[string](t, repo, "a/b", "c")
+
+	if _, err := repo.Lock(context.Background(), "../a"); !errors.Is(err, InvalidFilename) {
+		t.Fatalf("expected invalid name but got %v", err)
+	}
+
+	if n := repo.locks.Len(); n != 0 {
+		t.Fatalf("expected released locks but got %v", n)
+	}
+
+	// reading and writing while holding the lock of the same id does not deadlock
+	ctx := context.Background()
+	must("", crashStep{id: "a", data: []byte("0")}.apply(ctx, repo))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			unlock := must(repo.Lock(ctx, "a"))
+			defer unlock()
+
+			r := must(repo.Read(ctx, "a"))
+			n := must(strconv.Atoi(string(must(io.ReadAll(r)))))
+			must("", r.Close())
+			must("", crashStep{id: "a", data: []byte(strconv.Itoa(n + 1))}.apply(ctx, repo))
+		}()
+	}
+
+	wg.Wait()
+
+	r := must(repo.Read(ctx, "a"))
+	if buf := must(io.ReadAll(r)); string(buf) != "10" {
+		t.Fatalf("expected 10 but got %s", buf)
+	}
+	must("", r.Close())
+	repo.assertEmptyMutexes()
+}
+
+func TestBlobRepository_LockerPerID(t *testing.T) {
+	for name, fsys := range testLockFileSystems(t) {
+		fsys := fsys
+		t.Run(name, func(t *testing.T) {
+			// two instances behave like two processes, because their in-process locks are independent
+			a := must(NewBlobRepository[string](fsys, WithLocking(LockPerID)))
+			b := must(NewBlobRepository[string](fsys, WithLocking(LockPerID)))
+			test.TestLocker[string](t, a, "a/b", "c")
+			testLockerShared[string](t, a, b, "a/b")
+
+			// the advisory lock is independent from the file locks of Read and Write
+			ctx := context.Background()
+			unlock := must(a.Lock(ctx, "a/b"))
+			must("", crashStep{id: "a/b", data: []byte("hello")}.apply(ctx, b))
+			must("", unlock())
+		})
+	}
+}
+
+func TestRepository_LockerPerID(t *testing.T) {
+	for name, fsys := range testLockFileSystems(t) {
+		fsys := fsys
+		t.Run(name, func(t *testing.T) {
+			a := must(NewRepository[test.A, int](fsys, WithLocking(LockPerID)))
+			b := must(NewRepository[test.A, int](fsys, WithLocking(LockPerID)))
+			testLockerShared[int](t, a, b, 1)
+		})
+	}
+}
+
+// testLockerShared verifies, that the locks of two lockers sharing the same directory exclude each other.
+func testLockerShared[ID comparable](t *testing.T, a, b repository.Locker[ID], id ID) {
+	t.Helper()
+	ctx := context.Background()
+	wait := func() context.Context {
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		t.Cleanup(cancel)
+		return ctx
+	}
+
+	unlock := must(a.Lock(ctx, id))
+	if _, err := b.RLock(wait(), id); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected blocked read lock but got %v", err)
+	}
+
+	must("", unlock())
+
+	// shared locks only exclude exclusive ones
+	r1 := must(a.RLock(ctx, id))
+	r2 := must(b.RLock(wait(), id))
+	if _, err := b.Lock(wait(), id); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected blocked lock but got %v", err)
+	}
+
+	must("", r1())
+	must("", r2())
+
+	// an expired lease also releases the file lock
+	unlock = must(a.LockLease(ctx, id, 20*time.Millisecond))
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	must("", must(b.Lock(waitCtx, id))())
+	if err := unlock(); !errors.Is(err, repository.LeaseExpired) {
+		t.Fatalf("expected expired lease but got %v", err)
+	}
+}
+
+func TestRepository_Locker(t *testing.T) {
+	repo := must(NewRepository[test.A, int](NewMemFS()))
+	test.TestLocker[int](t, repo, 1, 2)
+}
@@ -0,0 +1,613 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault operations, which are passed to a Fault.
+const (
+	FaultOpen    = "open"
+	FaultWrite   = "write"
+	FaultSync    = "sync"
+	FaultRename  = "rename"
+	FaultRemove  = "remove"
+	FaultMkdir   = "mkdir"
+	FaultSyncDir = "syncdir"
+)
+
+// A Fault decides whether the operation on the named file fails. It returns nil to let the operation pass.
+type Fault func(op, name string) error
+
+// MemFS is an in-memory filesystem, which implements all write interfaces of this package with POSIX-like
+// semantics: a rename atomically replaces the destination, open files keep referring to their inode even if it has
+// been replaced or removed and directories must be created before files can be created within. Faults can be
+// injected for testing error paths. The zero value is not usable, use NewMemFS.
+type MemFS struct {
+	mutex    sync.Mutex
+	root     *memNode
+	fault    Fault
+	now      func() time.Time
+	locks    map[string]*memLock
+	unlocked *sync.Cond // unlocked is signalled, whenever a lock is released
+}
+
+type memLock struct {
+	shared    int
+	exclusive bool
+}
+
+type memNode struct {
+	name     string
+	dir      bool
+	data     []byte
+	children map[string]*memNode
+	modTime  time.Time
+}
+
+// NewMemFS creates an empty filesystem.
+func NewMemFS() *MemFS {
+	m := &MemFS{
+		root:  &memNode{name: ".", dir: true, children: map[string]*memNode{}},
+		now:   time.Now,
+		locks: map[string]*memLock{},
+	}
+
+	m.unlocked = sync.NewCond(&m.mutex)
+
+	return m
+}
+
+// Inject installs the given fault, which is consulted before each operation. A nil fault disables injection.
+func (m *MemFS) Inject(f Fault) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.fault = f
+}
+
+// check returns the injected error. The caller must hold the lock.
+func (m *MemFS) check(op, name string) error {
+	if m.fault == nil {
+		return nil
+	}
+
+	if err := m.fault(op, name); err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	return nil
+}
+
+// lookup returns the node of the name or nil. The caller must hold the lock.
+func (m *MemFS) lookup(name string) *memNode {
+	n := m.root
+	if name == "." {
+		return n
+	}
+
+	for _, segment := range strings.Split(name, "/") {
+		if !n.dir {
+			return nil
+		}
+
+		if n = n.children[segment]; n == nil {
+			return nil
+		}
+	}
+
+	return n
+}
+
+// parent returns the parent directory of name and its base name. The caller must hold the lock.
+func (m *MemFS) parent(op, name string) (*memNode, string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	dir, base := path.Split(name)
+	p := m.lookup(path.Clean(dir))
+	if p == nil {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	if !p.dir {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: errors.New("not a directory")}
+	}
+
+	return p, base, nil
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (m *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: FaultOpen, Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.check(FaultOpen, name); err != nil {
+		return nil, err
+	}
+
+	writeable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	n := m.lookup(name)
+	switch {
+	case n == nil && flag&os.O_CREATE == 0:
+		return nil, &fs.PathError{Op: FaultOpen, Path: name, Err: fs.ErrNotExist}
+	case n == nil:
+		p, base, err := m.parent(FaultOpen, name)
+		if err != nil {
+			return nil, err
+		}
+
+		n = &memNode{name: base, modTime: m.now()}
+		p.children[base] = n
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: FaultOpen, Path: name, Err: fs.ErrExist}
+	case n.dir && writeable:
+		return nil, &fs.PathError{Op: FaultOpen, Path: name, Err: errors.New("is a directory")}
+	}
+
+	if flag&os.O_TRUNC != 0 && writeable {
+		n.data = nil
+		n.modTime = m.now()
+	}
+
+	f := &memFile{fs: m, node: n, name: name, readable: flag&os.O_WRONLY == 0, writeable: writeable,
+		append: flag&os.O_APPEND != 0}
+
+	if n.dir {
+		f.entries = m.entries(n)
+	}
+
+	return f, nil
+}
+
+// entries returns the sorted directory entries. The caller must hold the lock.
+func (m *MemFS) entries(n *memNode) []fs.DirEntry {
+	res := make([]fs.DirEntry, 0, len(n.children))
+	for _, child := range n.children {
+		res = append(res, fs.FileInfoToDirEntry(child.info()))
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name() < res[j].Name()
+	})
+
+	return res
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	n := m.lookup(name)
+	if n == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if !n.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	return m.entries(n), nil
+}
+
+// Stat implements fs.StatFS.
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	n := m.lookup(name)
+	if n == nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return n.info(), nil
+}
+
+// Remove removes a file or an empty directory.
+func (m *MemFS) Remove(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p, base, err := m.parent(FaultRemove, name)
+	if err != nil {
+		return err
+	}
+
+	if err := m.check(FaultRemove, name); err != nil {
+		return err
+	}
+
+	n := p.children[base]
+	if n == nil {
+		return &fs.PathError{Op: FaultRemove, Path: name, Err: fs.ErrNotExist}
+	}
+
+	if n.dir && len(n.children) > 0 {
+		return &fs.PathError{Op: FaultRemove, Path: name, Err: errors.New("directory not empty")}
+	}
+
+	delete(p.children, base)
+
+	return nil
+}
+
+// Rename atomically moves oldpath to newpath and replaces any existing file or empty directory.
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	oldParent, oldBase, err := m.parent(FaultRename, oldpath)
+	if err != nil {
+		return err
+	}
+
+	newParent, newBase, err := m.parent(FaultRename, newpath)
+	if err != nil {
+		return err
+	}
+
+	if err := m.check(FaultRename, newpath); err != nil {
+		return err
+	}
+
+	n := oldParent.children[oldBase]
+	if n == nil {
+		return &fs.PathError{Op: FaultRename, Path: oldpath, Err: fs.ErrNotExist}
+	}
+
+	if oldpath == newpath {
+		return nil
+	}
+
+	if n.dir && strings.HasPrefix(newpath, oldpath+"/") {
+		return &fs.PathError{Op: FaultRename, Path: newpath, Err: fs.ErrInvalid}
+	}
+
+	if dst := newParent.children[newBase]; dst != nil {
+		switch {
+		case dst.dir && !n.dir:
+			return &fs.PathError{Op: FaultRename, Path: newpath, Err: errors.New("is a directory")}
+		case !dst.dir && n.dir:
+			return &fs.PathError{Op: FaultRename, Path: newpath, Err: errors.New("not a directory")}
+		case dst.dir && len(dst.children) > 0:
+			return &fs.PathError{Op: FaultRename, Path: newpath, Err: errors.New("directory not empty")}
+		}
+	}
+
+	delete(oldParent.children, oldBase)
+	n.name = newBase
+	newParent.children[newBase] = n
+
+	return nil
+}
+
+// MkdirAll creates the directory and all missing parents.
+func (m *MemFS) MkdirAll(name string) error {
+	name = path.Clean(name)
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: FaultMkdir, Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.check(FaultMkdir, name); err != nil {
+		return err
+	}
+
+	if name == "." {
+		return nil
+	}
+
+	n := m.root
+	for _, segment := range strings.Split(name, "/") {
+		child := n.children[segment]
+		if child == nil {
+			child = &memNode{name: segment, dir: true, children: map[string]*memNode{}, modTime: m.now()}
+			n.children[segment] = child
+		}
+
+		if !child.dir {
+			return &fs.PathError{Op: FaultMkdir, Path: name, Err: errors.New("not a directory")}
+		}
+
+		n = child
+	}
+
+	return nil
+}
+
+// SyncDir does nothing, because the filesystem is never persisted, but faults can be injected.
+func (m *MemFS) SyncDir(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: FaultSyncDir, Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.check(FaultSyncDir, name); err != nil {
+		return err
+	}
+
+	if n := m.lookup(name); n == nil || !n.dir {
+		return &fs.PathError{Op: FaultSyncDir, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return nil
+}
+
+// LockFile acquires a lock which behaves like flock between multiple calls, e.g. from multiple repositories sharing
+// this filesystem. The named file is created if required.
+func (m *MemFS) LockFile(name string, exclusive, wait bool) (io.Closer, error) {
+	f, err := m.OpenFile(name, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = f.Close()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for {
+		l := m.locks[name]
+		if l == nil {
+			l = &memLock{}
+			m.locks[name] = l
+		}
+
+		if !l.exclusive && (!exclusive || l.shared == 0) {
+			if exclusive {
+				l.exclusive = true
+			} else {
+				l.shared++
+			}
+
+			return &memLockFile{fs: m, name: name, exclusive: exclusive}, nil
+		}
+
+		if !wait {
+			return nil, &fs.PathError{Op: "lock", Path: name, Err: Locked}
+		}
+
+		m.unlocked.Wait()
+	}
+}
+
+// memLockFile releases a lock of a MemFS.
+type memLockFile struct {
+	fs        *MemFS
+	name      string
+	exclusive bool
+	once      sync.Once
+}
+
+func (f *memLockFile) Close() error {
+	f.once.Do(func() {
+		f.fs.mutex.Lock()
+		defer f.fs.mutex.Unlock()
+
+		l := f.fs.locks[f.name]
+		if f.exclusive {
+			l.exclusive = false
+		} else {
+			l.shared--
+		}
+
+		if !l.exclusive && l.shared == 0 {
+			delete(f.fs.locks, f.name)
+		}
+
+		f.fs.unlocked.Broadcast()
+	})
+
+	return nil
+}
+
+// Write atomically replaces the named file with the written data. The parent directory must exist.
+func (m *MemFS) Write(name string, w func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := w(&buf); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p, base, err := m.parent(FaultWrite, name)
+	if err != nil {
+		return err
+	}
+
+	for _, op := range []string{FaultWrite, FaultSync, FaultRename} {
+		if err := m.check(op, name); err != nil {
+			return err
+		}
+	}
+
+	if dst := p.children[base]; dst != nil && dst.dir {
+		return &fs.PathError{Op: FaultWrite, Path: name, Err: errors.New("is a directory")}
+	}
+
+	p.children[base] = &memNode{name: base, data: buf.Bytes(), modTime: m.now()}
+
+	return nil
+}
+
+func (n *memNode) info() memInfo {
+	return memInfo{name: n.name, size: int64(len(n.data)), dir: n.dir, modTime: n.modTime}
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.dir }
+func (i memInfo) Sys() any           { return nil }
+
+func (i memInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0700
+	}
+
+	return 0600
+}
+
+// memFile is an open file or directory, which refers to its node even if it has been renamed or removed.
+type memFile struct {
+	fs        *MemFS
+	node      *memNode
+	name      string
+	readable  bool
+	writeable bool
+	append    bool
+	offset    int64
+	entries   []fs.DirEntry // entries is the directory listing at open time
+	closed    bool
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	return f.node.info(), nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	switch {
+	case f.closed:
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	case f.node.dir:
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errors.New("is a directory")}
+	case !f.readable:
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrPermission}
+	case f.offset >= int64(len(f.node.data)):
+		return 0, io.EOF
+	}
+
+	n := copy(p, f.node.data[f.offset:])
+	f.offset += int64(n)
+
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	switch {
+	case f.closed:
+		return 0, &fs.PathError{Op: FaultWrite, Path: f.name, Err: fs.ErrClosed}
+	case !f.writeable:
+		return 0, &fs.PathError{Op: FaultWrite, Path: f.name, Err: fs.ErrPermission}
+	}
+
+	if err := f.fs.check(FaultWrite, f.name); err != nil {
+		return 0, err
+	}
+
+	if f.append {
+		f.offset = int64(len(f.node.data))
+	}
+
+	if end := f.offset + int64(len(p)); end > int64(len(f.node.data)) {
+		data := make([]byte, end)
+		copy(data, f.node.data)
+		f.node.data = data
+	}
+
+	n := copy(f.node.data[f.offset:], p)
+	f.offset += int64(n)
+	f.node.modTime = f.fs.now()
+
+	return n, nil
+}
+
+// Sync only injects faults, because there is nothing to persist.
+func (f *memFile) Sync() error {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.closed {
+		return &fs.PathError{Op: FaultSync, Path: f.name, Err: fs.ErrClosed}
+	}
+
+	return f.fs.check(FaultSync, f.name)
+}
+
+func (f *memFile) Close() error {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	f.closed = true
+
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (f *memFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.node.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a directory")}
+	}
+
+	rest := f.entries
+	if n <= 0 {
+		f.entries = nil
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(rest) {
+		n = len(rest)
+	}
+
+	f.entries = rest[n:]
+
+	return rest[:n], nil
+}
@@ -0,0 +1,96 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMemFS(t *testing.T) {
+	m := NewMemFS()
+	must("", m.MkdirAll("a/b"))
+	must("", m.Write("a/b/c.txt", func(w io.Writer) error {
+		_, err := io.WriteString(w, "hello")
+		return err
+	}))
+
+	f := must(m.OpenFile("a/d.txt", os.O_CREATE|os.O_WRONLY, 0600))
+	must(f.(WriteableFile).Write([]byte("world")))
+	must("", f.Close())
+
+	if err := fstest.TestFS(m, "a/b/c.txt", "a/d.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// an open file keeps its inode, even if replaced by rename
+	r := must(m.Open("a/b/c.txt"))
+	must("", m.Rename("a/d.txt", "a/b/c.txt"))
+	if buf := must(io.ReadAll(r)); string(buf) != "hello" {
+		t.Fatalf("expected old content but got %q", buf)
+	}
+	must("", r.Close())
+
+	if buf := must(fs.ReadFile(m, "a/b/c.txt")); string(buf) != "world" {
+		t.Fatalf("expected new content but got %q", buf)
+	}
+
+	if err := m.Remove("a"); err == nil {
+		t.Fatal("expected non-empty directory error")
+	}
+
+	if _, err := m.OpenFile("missing/x", os.O_CREATE|os.O_WRONLY, 0600); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected missing parent but got %v", err)
+	}
+
+	if _, err := m.Open("../x"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("expected invalid path but got %v", err)
+	}
+}
+
+func TestBlobRepository_Faults(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemFS()
+	repo := must(NewBlobRepository[string](m))
+
+	injected := errors.New("injected")
+	for _, op := range []string{FaultWrite, FaultSync, FaultRename} {
+		op := op
+		m.Inject(func(o, name string) error {
+			if o == op {
+				return injected
+			}
+
+			return nil
+		})
+
+		w, err := repo.Write(ctx, "a")
+		if err == nil {
+			_, err = w.Write([]byte("hello"))
+			if e := w.Close(); err == nil {
+				err = e
+			}
+		}
+
+		if !errors.Is(err, injected) {
+			t.Fatalf("%s: expected injected error but got %v", op, err)
+		}
+
+		m.Inject(nil)
+		if n := must(repo.Count(ctx)); n != 0 {
+			t.Fatalf("%s: expected no blob but got %v", op, n)
+		}
+
+		// the temporary file has been removed
+		for _, e := range must(m.ReadDir(".")) {
+			if !e.IsDir() {
+				t.Fatalf("%s: unexpected leftover %v", op, e.Name())
+			}
+		}
+	}
+
+	repo.assertEmptyMutexes()
+}
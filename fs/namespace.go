@@ -0,0 +1,147 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const droppedDir = ".dropped"
+
+// Namespaces partitions a filesystem into isolated tenants. Each tenant owns a top level directory, which contains
+// an ordinary repository layout, so that Count, FindAll and DeleteAll of a tenant repository never see the
+// entries of other tenants.
+type Namespaces struct {
+	fs fs.FS
+}
+
+// NewNamespaces uses the root of the given filesystem for tenant directories and removes the leftovers of
+// tenants, which have been dropped but not yet entirely deleted.
+func NewNamespaces(fsys fs.FS) (*Namespaces, error) {
+	n := &Namespaces{fs: fsys}
+	if err := removeAll(fsys, droppedDir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("cannot remove dropped tenants: %w", err)
+	}
+
+	return n, nil
+}
+
+// ValidTenant returns true, if the tenant is a ValidName without any directory separator and without leading
+// dot, which is reserved for internal purposes.
+func ValidTenant(tenant string) bool {
+	return ValidName(tenant) && !strings.Contains(tenant, "/") && !strings.HasPrefix(tenant, ".")
+}
+
+// FS returns the filesystem of the tenant, which supports the same write interfaces as the parent.
+func (n *Namespaces) FS(tenant string) (fs.FS, error) {
+	if !ValidTenant(tenant) {
+		return nil, InvalidFilename
+	}
+
+	return subFS{fsys: n.fs, dir: tenant}, nil
+}
+
+// Tenants returns all tenants in lexical order, which have a directory.
+func (n *Namespaces) Tenants(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(n.fs, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, e := range entries {
+		if e.IsDir() && ValidTenant(e.Name()) {
+			res = append(res, e.Name())
+		}
+	}
+
+	sort.Strings(res)
+
+	return res, nil
+}
+
+// Drop atomically removes the tenant by renaming its directory into a hidden location, before its content is
+// deleted. If deleting fails, the leftovers are removed by the next NewNamespaces. Repositories of the tenant
+// must not be used concurrently, otherwise a write may recreate the tenant. Dropping an unknown tenant is not an
+// error.
+func (n *Namespaces) Drop(ctx context.Context, tenant string) error {
+	if !ValidTenant(tenant) {
+		return InvalidFilename
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := mkdirAllDurable(n.fs, droppedDir); err != nil {
+		return err
+	}
+
+	tmp := droppedDir + "/" + tenant + "." + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := renameDurable(n.fs, tenant, tmp); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	_ = removeAll(n.fs, tmp) // best effort, the tenant is already gone
+
+	return nil
+}
+
+// NamespacedBlobRepository opens the BlobRepository of the tenant.
+func NamespacedBlobRepository[ID Name](n *Namespaces, tenant string, opts ...Option) (*BlobRepository[ID], error) {
+	fsys, err := n.FS(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBlobRepository[ID](fsys, opts...)
+}
+
+// NamespacedRepository opens the Repository of the tenant.
+func NamespacedRepository[T any, ID comparable](n *Namespaces, tenant string, opts ...Option) (*Repository[T, ID], error) {
+	fsys, err := n.FS(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRepository[T, ID](fsys, opts...)
+}
+
+// removeAll removes the named file or directory recursively.
+func removeAll(fsys fs.FS, name string) error {
+	var names []string
+	err := fs.WalkDir(fsys, name, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		names = append(names, path)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// children are always visited after their parent
+	for i := len(names) - 1; i >= 0; i-- {
+		if err := Remove(fsys, names[i]); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package fs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNamespaces(t *testing.T) {
+	ctx := context.Background()
+	ns := must(NewNamespaces(Dir(t.TempDir())))
+
+	alice := must(NamespacedRepository[string, int](ns, "alice"))
+	bob := must(NamespacedRepository[string, int](ns, "bob"))
+	must("", alice.Save(1, "alice"))
+	must("", bob.Save(1, "bob"))
+	must("", bob.Save(2, "bob"))
+
+	must("", alice.DeleteAll())
+	if n := must(bob.Count()); n != 2 {
+		t.Fatalf("DeleteAll must be scoped to the tenant, but got %v", n)
+	}
+
+	if _, err := NamespacedRepository[string, int](ns, "../bob"); err != InvalidFilename {
+		t.Fatalf("expected invalid tenant but got %v", err)
+	}
+
+	if tenants := must(ns.Tenants(ctx)); !reflect.DeepEqual(tenants, []string{"alice", "bob"}) {
+		t.Fatalf("unexpected tenants %v", tenants)
+	}
+
+	must("", ns.Drop(ctx, "bob"))
+	if tenants := must(ns.Tenants(ctx)); !reflect.DeepEqual(tenants, []string{"alice"}) {
+		t.Fatalf("unexpected tenants %v", tenants)
+	}
+
+	if n := must(must(NamespacedBlobRepository[string](ns, "bob")).Count(ctx)); n != 0 {
+		t.Fatalf("expected dropped tenant but got %v entries", n)
+	}
+}
+
+func TestNamespaces_DropDurable(t *testing.T) {
+	ctx := context.Background()
+	f := NewFaultFS(NewMemFS())
+	ns := must(NewNamespaces(f))
+	must(NamespacedBlobRepository[string](ns, "bob"))
+
+	setup := len(f.Trace())
+	must("", ns.Drop(ctx, "bob"))
+
+	// the rename of the dropped tenant must be flushed by both parents
+	synced := map[string]bool{}
+	for _, op := range f.Trace()[setup:] {
+		if op.Op == FaultSyncDir {
+			synced[op.Name] = true
+		}
+	}
+
+	if !synced["."] || !synced[droppedDir] {
+		t.Fatalf("expected synced directories but got %v", synced)
+	}
+}
@@ -0,0 +1,176 @@
+package fs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const atRemoveDir = 0x200 // AT_REMOVEDIR is not exported by syscall
+
+// noFollowError converts the errno of a symbolic link into SymlinkNotFollowed.
+func noFollowError(op, name string, err error) error {
+	if errors.Is(err, syscall.ELOOP) {
+		err = SymlinkNotFollowed
+	}
+
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// openParent opens the parent directory of name relative to the root without following any symbolic link and
+// returns its file descriptor and the base name. The caller must close the descriptor.
+func (l dirFS) openParent(op, name string) (int, string, error) {
+	fd, err := syscall.Open(l.root, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	dir, base := path.Split(name)
+	if dir == "" {
+		return fd, base, nil
+	}
+
+	for _, elem := range strings.Split(strings.TrimSuffix(dir, "/"), "/") {
+		next, err := openDirAt(fd, elem)
+		_ = syscall.Close(fd)
+		if err != nil {
+			return -1, "", noFollowError(op, name, err)
+		}
+
+		fd = next
+	}
+
+	return fd, base, nil
+}
+
+// openDirAt opens the named directory relative to dirfd. Combining O_DIRECTORY with O_NOFOLLOW reports a symbolic
+// link as ENOTDIR, so the type is checked afterwards to report ELOOP instead.
+func openDirAt(dirfd int, name string) (int, error) {
+	fd, err := syscall.Openat(dirfd, name, syscall.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, err
+	}
+
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(fd, &stat); err != nil {
+		_ = syscall.Close(fd)
+		return -1, err
+	}
+
+	if stat.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		_ = syscall.Close(fd)
+		return -1, syscall.ENOTDIR
+	}
+
+	return fd, nil
+}
+
+func (l dirFS) openNoFollow(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	if name == "." {
+		return os.OpenFile(l.root, flag, perm)
+	}
+
+	dirfd, base, err := l.openParent("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer syscall.Close(dirfd)
+
+	fd, err := syscall.Openat(dirfd, base, flag|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, uint32(perm.Perm()))
+	if err != nil {
+		return nil, noFollowError("open", name, err)
+	}
+
+	return os.NewFile(uintptr(fd), filepath.Join(l.root, name)), nil
+}
+
+func (l dirFS) removeNoFollow(name string) error {
+	dirfd, base, err := l.openParent("remove", name)
+	if err != nil {
+		return err
+	}
+
+	defer syscall.Close(dirfd)
+
+	err = syscall.Unlinkat(dirfd, base)
+	if errors.Is(err, syscall.EISDIR) {
+		err = unlinkat(dirfd, base, atRemoveDir)
+	}
+
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+
+	return nil
+}
+
+func unlinkat(dirfd int, name string, flags int) error {
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_UNLINKAT, uintptr(dirfd), uintptr(unsafe.Pointer(p)), uintptr(flags))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+func (l dirFS) renameNoFollow(oldpath, newpath string) error {
+	oldfd, oldbase, err := l.openParent("rename", oldpath)
+	if err != nil {
+		return err
+	}
+
+	defer syscall.Close(oldfd)
+
+	newfd, newbase, err := l.openParent("rename", newpath)
+	if err != nil {
+		return err
+	}
+
+	defer syscall.Close(newfd)
+
+	// renameat never follows the final component
+	if err := syscall.Renameat(oldfd, oldbase, newfd, newbase); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+
+	return nil
+}
+
+func (l dirFS) mkdirAllNoFollow(name string) error {
+	fd, err := syscall.Open(l.root, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+
+	if name == "." {
+		return syscall.Close(fd)
+	}
+
+	for _, elem := range strings.Split(name, "/") {
+		if err := syscall.Mkdirat(fd, elem, 0700); err != nil && !errors.Is(err, syscall.EEXIST) {
+			_ = syscall.Close(fd)
+			return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+		}
+
+		next, err := openDirAt(fd, elem)
+		_ = syscall.Close(fd)
+		if err != nil {
+			return noFollowError("mkdir", name, err)
+		}
+
+		fd = next
+	}
+
+	return syscall.Close(fd)
+}
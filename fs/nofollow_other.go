@@ -0,0 +1,71 @@
+//go:build !linux
+
+package fs
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// checkNoFollow returns SymlinkNotFollowed, if any existing component of name is a symbolic link. This is racy,
+// because a component may be replaced after the check.
+func (l dirFS) checkNoFollow(op, name string) error {
+	if name == "." {
+		return nil
+	}
+
+	prefix := ""
+	for _, elem := range strings.Split(name, "/") {
+		prefix = path.Join(prefix, elem)
+		info, err := os.Lstat(filepath.Join(l.root, filepath.FromSlash(prefix)))
+		if err != nil {
+			return nil // the operation itself reports missing components
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return &fs.PathError{Op: op, Path: name, Err: SymlinkNotFollowed}
+		}
+	}
+
+	return nil
+}
+
+func (l dirFS) openNoFollow(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	if err := l.checkNoFollow("open", name); err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(filepath.Join(l.root, filepath.FromSlash(name)), flag, perm)
+}
+
+func (l dirFS) removeNoFollow(name string) error {
+	// removing the final symbolic link itself is harmless
+	if err := l.checkNoFollow("remove", path.Dir(name)); err != nil {
+		return err
+	}
+
+	return os.Remove(filepath.Join(l.root, filepath.FromSlash(name)))
+}
+
+func (l dirFS) renameNoFollow(oldpath, newpath string) error {
+	if err := l.checkNoFollow("rename", path.Dir(oldpath)); err != nil {
+		return err
+	}
+
+	if err := l.checkNoFollow("rename", path.Dir(newpath)); err != nil {
+		return err
+	}
+
+	return os.Rename(filepath.Join(l.root, filepath.FromSlash(oldpath)), filepath.Join(l.root, filepath.FromSlash(newpath)))
+}
+
+func (l dirFS) mkdirAllNoFollow(name string) error {
+	if err := l.checkNoFollow("mkdir", name); err != nil {
+		return err
+	}
+
+	return os.MkdirAll(filepath.Join(l.root, filepath.FromSlash(name)), 0700)
+}
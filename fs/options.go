@@ -0,0 +1,117 @@
+package fs
+
+import (
+	"github.com/golangee/repository/schema"
+	"time"
+)
+
+// Option configures the repositories of this package.
+type Option func(*options)
+
+type options struct {
+	pollInterval     time.Duration
+	schema           *schema.Registry
+	softDelete       bool
+	retention        time.Duration
+	now              func() time.Time
+	history          bool
+	revisions        int
+	historyRetention time.Duration
+	readOnly         bool
+	locking          Locking
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		pollInterval: time.Second,
+		now:          time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithPollInterval sets the interval in which the directory is scanned for changes made by other processes,
+// while at least one watcher is subscribed. Defaults to one second.
+func WithPollInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.pollInterval = d
+		}
+	}
+}
+
+// WithSchema wraps each entity saved by a Repository into an envelope carrying the current schema version of the
+// registry. Entities saved with an older version or without any envelope (version 0) are upcasted when they are
+// decoded, see also Repository.Upgrade.
+func WithSchema(reg *schema.Registry) Option {
+	return func(o *options) {
+		o.schema = reg
+	}
+}
+
+// WithSoftDelete moves deleted blobs or entities into the hidden .trash directory instead of removing them, so that
+// they can be restored. Trashed entries older than the retention period are purged when opening the repository,
+// at most once per hour while deleting or by calling PurgeDeleted explicitly. A retention <= 0 keeps trashed
+// entries forever.
+func WithSoftDelete(retention time.Duration) Option {
+	return func(o *options) {
+		o.softDelete = true
+		o.retention = retention
+	}
+}
+
+// WithClock replaces the wall clock, which is used to timestamp and purge trashed entries.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithHistory records a revision of an entity on each save or delete, so that earlier versions can be inspected
+// using History and FindByIDAt. Revisions are stored as hidden sibling files beside the current entity file.
+// Besides the current revision, at most the given amount of previous revisions is kept and previous revisions
+// older than the retention period are discarded. Values <= 0 mean unlimited. This is only supported by Repository
+// and ignored by BlobRepository.
+func WithHistory(revisions int, retention time.Duration) Option {
+	return func(o *options) {
+		o.history = true
+		o.revisions = revisions
+		o.historyRetention = retention
+	}
+}
+
+// WithReadOnly opens the repository without creating the fanout directories, so that it can be used on a read-only
+// filesystem like embed.FS. All modifications fail with ReadOnly. See also Overlay to layer modifications on top
+// of a read-only filesystem.
+func WithReadOnly() Option {
+	return func(o *options) {
+		o.readOnly = true
+	}
+}
+
+// Locking selects, how a repository coordinates with other processes sharing the same directory.
+type Locking int
+
+const (
+	// LockNone only coordinates the goroutines using the same repository instance. This is the default.
+	LockNone Locking = iota
+	// LockDirectory acquires an exclusive lock of the hidden .lock file when opening the repository, so that
+	// opening the same directory again fails with Locked, until the owning repository has been closed.
+	LockDirectory
+	// LockPerID acquires a shared lock of a hidden lock file per ID while reading and an exclusive one while
+	// modifying it, so that multiple repositories can safely share the directory. Lock files are named by the
+	// sha256 hash of the ID, kept in the hidden .locks directory and are never removed.
+	LockPerID
+)
+
+// WithLocking enables advisory file locks to coordinate with other processes, which requires a filesystem
+// implementing LockFileFS. Close the repository to release its locks.
+func WithLocking(mode Locking) Option {
+	return func(o *options) {
+		o.locking = mode
+	}
+}
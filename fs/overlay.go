@@ -0,0 +1,445 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// whiteoutPrefix marks a hidden file in the upper layer of an overlay, which hides the entry of the lower layer
+// with the same name without the prefix.
+const whiteoutPrefix = ".wh."
+
+type overlayFS struct {
+	lower fs.FS
+	upper fs.FS
+}
+
+// Overlay returns a copy-on-write filesystem which reads from the read-only lower layer, e.g. an embed.FS, and
+// applies all modifications to the upper layer, which must support the write interfaces of this package.
+// Files of the upper layer shadow files of the lower layer. A file of the lower layer is copied up before it is
+// opened for writing and deleted files of the lower layer are hidden by whiteout markers, which are never removed,
+// because an entry of the upper layer always takes precedence. Directories of the lower layer cannot be renamed.
+func Overlay(lower, upper fs.FS) fs.FS {
+	return overlayFS{lower: lower, upper: upper}
+}
+
+// check rejects invalid paths and reserved whiteout names.
+func (o overlayFS) check(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name != "." {
+		for _, elem := range strings.Split(name, "/") {
+			if strings.HasPrefix(elem, whiteoutPrefix) {
+				return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+			}
+		}
+	}
+
+	return nil
+}
+
+func whiteoutName(name string) string {
+	dir, base := path.Split(name)
+	return dir + whiteoutPrefix + base
+}
+
+// lowerVisible returns false, if the name or any of its parents has been deleted in the upper layer.
+func (o overlayFS) lowerVisible(name string) bool {
+	if name == "." {
+		return true
+	}
+
+	prefix := ""
+	for _, elem := range strings.Split(name, "/") {
+		prefix = path.Join(prefix, elem)
+		if _, err := fs.Stat(o.upper, whiteoutName(prefix)); err == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// lowerStat returns the info of the visible entry of the lower layer.
+func (o overlayFS) lowerStat(name string) (fs.FileInfo, error) {
+	if !o.lowerVisible(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return fs.Stat(o.lower, name)
+}
+
+// Stat implements fs.StatFS.
+func (o overlayFS) Stat(name string) (fs.FileInfo, error) {
+	if err := o.check("stat", name); err != nil {
+		return nil, err
+	}
+
+	if info, err := fs.Stat(o.upper, name); err == nil {
+		return info, nil
+	}
+
+	return o.lowerStat(name)
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if err := o.check("open", name); err != nil {
+		return nil, err
+	}
+
+	info, err := fs.Stat(o.upper, name)
+	if err != nil {
+		if info, err = o.lowerStat(name); err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+
+		if !info.IsDir() {
+			return o.lower.Open(name)
+		}
+	}
+
+	if !info.IsDir() {
+		return o.upper.Open(name)
+	}
+
+	entries, err := o.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &overlayDir{info: info, entries: entries}, nil
+}
+
+// ReadDir implements fs.ReadDirFS and merges both layers.
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := o.check("readdir", name); err != nil {
+		return nil, err
+	}
+
+	info, err := o.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	merged := map[string]fs.DirEntry{}
+	hidden := map[string]bool{}
+	upper, upperErr := fs.ReadDir(o.upper, name)
+	for _, e := range upper {
+		if strings.HasPrefix(e.Name(), whiteoutPrefix) {
+			hidden[strings.TrimPrefix(e.Name(), whiteoutPrefix)] = true
+			continue
+		}
+
+		merged[e.Name()] = e
+	}
+
+	var lowerErr error = fs.ErrNotExist
+	if o.lowerVisible(name) {
+		var lower []fs.DirEntry
+		lower, lowerErr = fs.ReadDir(o.lower, name)
+		for _, e := range lower {
+			if _, ok := merged[e.Name()]; !ok && !hidden[e.Name()] {
+				merged[e.Name()] = e
+			}
+		}
+	}
+
+	if upperErr != nil && lowerErr != nil {
+		return nil, upperErr
+	}
+
+	res := make([]fs.DirEntry, 0, len(merged))
+	for _, e := range merged {
+		res = append(res, e)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name() < res[j].Name()
+	})
+
+	return res, nil
+}
+
+// OpenFile opens files for writing in the upper layer and copies files of the lower layer up before, unless
+// they are truncated anyway.
+func (o overlayFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		return o.Open(name)
+	}
+
+	if err := o.check("open", name); err != nil {
+		return nil, err
+	}
+
+	if _, err := fs.Stat(o.upper, name); errors.Is(err, fs.ErrNotExist) {
+		if info, err := o.lowerStat(name); err == nil {
+			if info.IsDir() {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+			}
+
+			if flag&os.O_EXCL != 0 {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+			}
+		}
+
+		if err := o.copyUp("open", name, flag&os.O_TRUNC == 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return OpenFile(o.upper, name, flag, perm)
+}
+
+// copyUp ensures, that the parent directory exists in the upper layer and copies the visible lower file, if
+// requested. If the lower file does not exist, only the parent is created.
+func (o overlayFS) copyUp(op, name string, data bool) error {
+	if dir := path.Dir(name); dir != "." {
+		info, err := o.Stat(dir)
+		if err != nil {
+			return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+
+		if !info.IsDir() {
+			return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+		}
+
+		if err := MkdirAll(o.upper, dir); err != nil {
+			return err
+		}
+	}
+
+	if !data {
+		return nil
+	}
+
+	info, err := o.lowerStat(name)
+	if err != nil || info.IsDir() {
+		return nil // nothing to copy, a directory is rejected by the upper layer
+	}
+
+	src, err := o.lower.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	return Write(o.upper, name, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+}
+
+// whiteout hides the lower entry, if there is any.
+func (o overlayFS) whiteout(name string) error {
+	if _, err := o.lowerStat(name); err != nil {
+		return nil
+	}
+
+	file, err := OpenFile(o.upper, whiteoutName(name), os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// Remove removes files and empty directories from the merged view.
+func (o overlayFS) Remove(name string) error {
+	if err := o.check("remove", name); err != nil {
+		return err
+	}
+
+	info, err := o.Stat(name)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if info.IsDir() {
+		entries, err := o.ReadDir(name)
+		if err != nil {
+			return err
+		}
+
+		if len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+		}
+	}
+
+	if err := o.copyUp("remove", name, false); err != nil {
+		return err
+	}
+
+	// hide the lower entry first, so that a failure never resurrects it
+	if err := o.whiteout(name); err != nil {
+		return err
+	}
+
+	if err := o.removeUpper(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// removeUpper removes the upper entry including any whiteout markers of an upper directory.
+func (o overlayFS) removeUpper(name string) error {
+	entries, err := fs.ReadDir(o.upper, name)
+	if err == nil {
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), whiteoutPrefix) {
+				if err := Remove(o.upper, path.Join(name, e.Name())); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return Remove(o.upper, name)
+}
+
+// Rename moves a file within the merged view. Files of the lower layer are copied up before.
+func (o overlayFS) Rename(oldpath, newpath string) error {
+	if err := o.check("rename", oldpath); err != nil {
+		return err
+	}
+
+	if err := o.check("rename", newpath); err != nil {
+		return err
+	}
+
+	info, err := fs.Stat(o.upper, oldpath)
+	if errors.Is(err, fs.ErrNotExist) {
+		if info, err = o.lowerStat(oldpath); err != nil {
+			return &fs.PathError{Op: "rename", Path: oldpath, Err: fs.ErrNotExist}
+		}
+
+		if info.IsDir() {
+			return &fs.PathError{Op: "rename", Path: oldpath, Err: RenameFileNotSupported}
+		}
+
+		if err := o.copyUp("rename", oldpath, true); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if info.IsDir() {
+		if lower, err := o.lowerStat(oldpath); err == nil && lower.IsDir() {
+			return &fs.PathError{Op: "rename", Path: oldpath, Err: RenameFileNotSupported}
+		}
+	}
+
+	if err := o.copyUp("rename", newpath, false); err != nil {
+		return err
+	}
+
+	// the upper entry still shadows the whiteout, so that a failure never resurrects the lower entry
+	if err := o.whiteout(oldpath); err != nil {
+		return err
+	}
+
+	return Rename(o.upper, oldpath, newpath)
+}
+
+// MkdirAll creates all directories in the upper layer, as far as they do not exist already in the merged view.
+func (o overlayFS) MkdirAll(name string) error {
+	if err := o.check("mkdir", name); err != nil {
+		return err
+	}
+
+	if info, err := o.Stat(name); err == nil && !info.IsDir() {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+
+	return MkdirAll(o.upper, name)
+}
+
+// SyncDir flushes the directory of the upper layer. A directory, which exists only in the lower layer, has no
+// modifications to flush.
+func (o overlayFS) SyncDir(name string) error {
+	if err := o.check("syncdir", name); err != nil {
+		return err
+	}
+
+	if _, err := fs.Stat(o.upper, name); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return SyncDir(o.upper, name)
+}
+
+// LockFile locks the file of the upper layer.
+func (o overlayFS) LockFile(name string, exclusive, wait bool) (io.Closer, error) {
+	if err := o.check("lock", name); err != nil {
+		return nil, err
+	}
+
+	if err := o.copyUp("lock", name, true); err != nil {
+		return nil, err
+	}
+
+	return LockFile(o.upper, name, exclusive, wait)
+}
+
+// Write writes the file transactionally into the upper layer.
+func (o overlayFS) Write(name string, w func(w io.Writer) error) error {
+	if err := o.check("write", name); err != nil {
+		return err
+	}
+
+	if err := o.copyUp("write", name, false); err != nil {
+		return err
+	}
+
+	return Write(o.upper, name, w)
+}
+
+// overlayDir is an opened directory of the merged view.
+type overlayDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *overlayDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *overlayDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: fs.ErrInvalid}
+}
+
+func (d *overlayDir) Close() error {
+	return nil
+}
+
+func (d *overlayDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(rest) {
+		n = len(rest)
+	}
+
+	d.offset += n
+
+	return rest[:n], nil
+}
@@ -0,0 +1,135 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository/internal/test"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOverlay(t *testing.T) {
+	lower := fstest.MapFS{
+		"a/b.txt": {Data: []byte("lower b")},
+		"a/c.txt": {Data: []byte("lower c")},
+		"d.txt":   {Data: []byte("lower d")},
+		"e.txt":   {Data: []byte("lower e")},
+	}
+
+	o := Overlay(lower, NewMemFS())
+	if err := fstest.TestFS(o, "a/b.txt", "a/c.txt", "d.txt", "e.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	must("", Write(o, "a/b.txt", func(w io.Writer) error {
+		_, err := io.WriteString(w, "upper b")
+		return err
+	}))
+
+	// copy up before appending
+	f := must(OpenFile(o, "d.txt", os.O_WRONLY|os.O_APPEND, 0600))
+	must(f.(WriteableFile).Write([]byte(" appended")))
+	must("", f.Close())
+
+	must("", Remove(o, "a/c.txt"))
+	must("", Rename(o, "e.txt", "f.txt"))
+
+	if err := fstest.TestFS(o, "a/b.txt", "d.txt", "f.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{"a/b.txt": "upper b", "d.txt": "lower d appended", "f.txt": "lower e"} {
+		if buf := must(fs.ReadFile(o, name)); string(buf) != expected {
+			t.Fatalf("%s: expected %q but got %q", name, expected, buf)
+		}
+	}
+
+	for _, name := range []string{"a/c.txt", "e.txt"} {
+		if _, err := fs.Stat(o, name); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("%s: expected deleted but got %v", name, err)
+		}
+	}
+
+	if err := Remove(o, "a"); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected non-empty directory but got %v", err)
+	}
+
+	// a re-created directory does not resurrect deleted lower entries
+	must("", Remove(o, "a/b.txt"))
+	must("", Remove(o, "a"))
+	must("", MkdirAll(o, "a"))
+	if entries := must(fs.ReadDir(o, "a")); len(entries) != 0 {
+		t.Fatalf("expected empty directory but got %v", entries)
+	}
+
+	f = must(OpenFile(o, "e.txt", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600))
+	must("", f.Close())
+	if buf := must(fs.ReadFile(o, "e.txt")); len(buf) != 0 {
+		t.Fatalf("expected new empty file but got %q", buf)
+	}
+
+	if _, err := OpenFile(o, "d.txt", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected existing file but got %v", err)
+	}
+
+	if _, err := o.Open(".wh.d.txt"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("expected reserved name but got %v", err)
+	}
+
+	// the lower layer is never touched
+	if len(lower) != 4 || string(lower["a/b.txt"].Data) != "lower b" || string(lower["d.txt"].Data) != "lower d" {
+		t.Fatalf("lower layer has been modified: %v", lower)
+	}
+}
+
+func TestOverlay_Repository(t *testing.T) {
+	lower := NewMemFS()
+	seed := must(NewRepository[*test.B, int](lower))
+	for _, b := range test.CreateTestSet3() {
+		must("", seed.Save(b.ID, b.Entity))
+	}
+
+	repo := must(NewRepository[*test.B, int](Overlay(lower, NewMemFS())))
+	if n := must(repo.Count()); n != int64(len(test.CreateTestSet3())) {
+		t.Fatalf("expected seed data but got %v entities", n)
+	}
+
+	// deleting the seed data only creates whiteouts
+	must("", repo.DeleteAll())
+	test.Test[*test.B, int](t, test.CreateTestSet3(), repo)
+
+	if n := must(seed.Count()); n != int64(len(test.CreateTestSet3())) {
+		t.Fatalf("lower layer has been modified: %v entities", n)
+	}
+}
+
+func TestBlobRepository_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	repo := must(NewBlobRepository[string](fstest.MapFS{"a/b": {Data: []byte("b")}}, WithReadOnly()))
+	if n := must(repo.Count(ctx)); n != 1 {
+		t.Fatalf("expected 1 but got %v", n)
+	}
+
+	r := must(repo.Read(ctx, "a/b"))
+	if buf := must(io.ReadAll(r)); string(buf) != "b" {
+		t.Fatalf("unexpected blob %q", buf)
+	}
+	must("", r.Close())
+
+	if _, err := repo.Write(ctx, "a/c"); !errors.Is(err, ReadOnly) {
+		t.Fatalf("expected read-only but got %v", err)
+	}
+
+	if err := repo.Delete(ctx, "a/b"); !errors.Is(err, ReadOnly) {
+		t.Fatalf("expected read-only but got %v", err)
+	}
+
+	if err := repo.DeleteAll(ctx); !errors.Is(err, ReadOnly) {
+		t.Fatalf("expected read-only but got %v", err)
+	}
+
+	repo.assertEmptyMutexes()
+}
@@ -0,0 +1,57 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golangee/repository/schema"
+	"testing"
+)
+
+type personV0 struct {
+	Name string
+}
+
+type personV1 struct {
+	Firstname string
+}
+
+func TestRepository_Schema(t *testing.T) {
+	dir := Dir(t.TempDir())
+	old := must(NewRepository[personV0, int](dir))
+	must("", old.Save(1, personV0{Name: "otto"}))
+
+	reg := schema.NewRegistry(1).Register(0, func(doc json.RawMessage) (json.RawMessage, error) {
+		var v map[string]any
+		if err := json.Unmarshal(doc, &v); err != nil {
+			return nil, err
+		}
+
+		v["Firstname"] = v["Name"]
+		delete(v, "Name")
+
+		return json.Marshal(v)
+	})
+
+	repo := must(NewRepository[personV1, int](dir, WithSchema(reg)))
+	if p := must(repo.FindByID(1)); p.Firstname != "otto" {
+		t.Fatalf("expected upcasted entity but got %v", p)
+	}
+
+	if n := must(repo.Upgrade(context.Background())); n != 1 {
+		t.Fatalf("expected 1 upgrade but got %v", n)
+	}
+
+	if n := must(repo.Upgrade(context.Background())); n != 0 {
+		t.Fatalf("expected 0 upgrades but got %v", n)
+	}
+
+	// the old repository does not understand the new version anymore
+	if _, err := old.FindByID(1); err == nil {
+		t.Fatal("expected version error")
+	}
+
+	must("", repo.Save(2, personV1{Firstname: "ada"}))
+	if p := must(repo.FindByID(2)); p.Firstname != "ada" {
+		t.Fatalf("unexpected entity %v", p)
+	}
+}
@@ -0,0 +1,98 @@
+package fs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+)
+
+// subFS is like fs.Sub but also supports all write interfaces of this package, as far as the parent does.
+type subFS struct {
+	fsys fs.FS
+	dir  string
+}
+
+func (s subFS) name(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return path.Join(s.dir, name), nil
+}
+
+func (s subFS) Open(name string) (fs.File, error) {
+	full, err := s.name("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.fsys.Open(full)
+}
+
+func (s subFS) Remove(name string) error {
+	full, err := s.name("remove", name)
+	if err != nil {
+		return err
+	}
+
+	return Remove(s.fsys, full)
+}
+
+func (s subFS) Rename(oldpath, newpath string) error {
+	oldFull, err := s.name("rename", oldpath)
+	if err != nil {
+		return err
+	}
+
+	newFull, err := s.name("rename", newpath)
+	if err != nil {
+		return err
+	}
+
+	return Rename(s.fsys, oldFull, newFull)
+}
+
+func (s subFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	full, err := s.name("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenFile(s.fsys, full, flag, perm)
+}
+
+func (s subFS) MkdirAll(name string) error {
+	full, err := s.name("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	return MkdirAll(s.fsys, full)
+}
+
+func (s subFS) SyncDir(name string) error {
+	full, err := s.name("syncdir", name)
+	if err != nil {
+		return err
+	}
+
+	return SyncDir(s.fsys, full)
+}
+
+func (s subFS) LockFile(name string, exclusive, wait bool) (io.Closer, error) {
+	full, err := s.name("lock", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return LockFile(s.fsys, full, exclusive, wait)
+}
+
+func (s subFS) Write(name string, w func(w io.Writer) error) error {
+	full, err := s.name("write", name)
+	if err != nil {
+		return err
+	}
+
+	return Write(s.fsys, full, w)
+}
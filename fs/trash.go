@@ -0,0 +1,240 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	trashDir       = ".trash"
+	trashExt       = ".del"
+	purgeThrottle  = time.Hour
+	trashPrefixLen = len(trashDir) + 1
+)
+
+// trash tracks the automatic purging of trashed entries.
+type trash struct {
+	mutex     sync.Mutex
+	lastPurge time.Time
+}
+
+// trashName returns the hidden name of a trashed blob, which encodes the deletion time:
+//
+//	.trash/<id>.<unix nano>.del
+func trashName[ID Name](id ID, deletedAt time.Time) string {
+	return trashDir + "/" + string(id) + "." + strconv.FormatInt(deletedAt.UnixNano(), 10) + trashExt
+}
+
+// parseTrashName is the inverse of trashName.
+func parseTrashName[ID Name](name string) (ID, time.Time, bool) {
+	var id ID
+	if !strings.HasPrefix(name, trashDir+"/") || !strings.HasSuffix(name, trashExt) {
+		return id, time.Time{}, false
+	}
+
+	name = strings.TrimSuffix(name[trashPrefixLen:], trashExt)
+	dot := strings.LastIndexByte(name, '.')
+	if dot < 0 {
+		return id, time.Time{}, false
+	}
+
+	nanos, err := strconv.ParseInt(name[dot+1:], 10, 64)
+	if err != nil {
+		return id, time.Time{}, false
+	}
+
+	return ID(name[:dot]), time.Unix(0, nanos), true
+}
+
+// moveToTrash renames the blob into the trash. The caller must hold the write lock of the id.
+func (r *BlobRepository[ID]) moveToTrash(id ID) (bool, error) {
+	if _, err := fs.Stat(r.fs, string(id)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	name := trashName(id, r.opts.now())
+	if err := mkdirAllDurable(r.fs, path.Dir(name)); err != nil {
+		return false, err
+	}
+
+	if err := renameDurable(r.fs, string(id), name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil // deleted concurrently by another process
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ListDeleted returns all trashed blobs ordered by deletion time. The same ID may be contained multiple times,
+// if it has been deleted multiple times.
+func (r *BlobRepository[ID]) ListDeleted(ctx context.Context) ([]repository.Trashed[ID], error) {
+	var res []repository.Trashed[ID]
+	err := fs.WalkDir(r.fs, trashDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && name == trashDir {
+				return fs.SkipDir
+			}
+
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		if id, deletedAt, ok := parseTrashName[ID](name); ok {
+			res = append(res, repository.Trashed[ID]{ID: id, DeletedAt: deletedAt})
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].DeletedAt.Before(res[j].DeletedAt)
+	})
+
+	return res, nil
+}
+
+// Restore moves the most recently trashed version of the blob back. It fails with fs.ErrNotExist, if no such
+// trashed blob exists and with fs.ErrExist, if the blob has been written again in the meantime.
+func (r *BlobRepository[ID]) Restore(ctx context.Context, id ID) error {
+	if r.opts.readOnly {
+		return ReadOnly
+	}
+
+	if !ValidName(id) {
+		return InvalidFilename
+	}
+
+	return r.locked(id, func() error {
+		return r.restore(id)
+	})
+}
+
+// restore is like Restore, but the caller must hold the write lock of the id.
+func (r *BlobRepository[ID]) restore(id ID) error {
+	latest, err := r.latestTrashed(id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fs.Stat(r.fs, string(id)); err == nil {
+		return &fs.PathError{Op: "restore", Path: string(id), Err: fs.ErrExist}
+	}
+
+	if err := mkdirAllDurable(r.fs, path.Dir(string(id))); err != nil {
+		return err
+	}
+
+	if err := renameDurable(r.fs, latest, string(id)); err != nil {
+		return err
+	}
+
+	r.notify(repository.Saved, id)
+
+	return nil
+}
+
+// latestTrashed returns the name of the most recently trashed version of the id.
+func (r *BlobRepository[ID]) latestTrashed(id ID) (string, error) {
+	dir := path.Dir(trashDir + "/" + string(id))
+	entries, err := fs.ReadDir(r.fs, dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	var latest string
+	var latestAt time.Time
+	for _, e := range entries {
+		name := dir + "/" + e.Name()
+		if e.IsDir() {
+			continue
+		}
+
+		if trashedID, deletedAt, ok := parseTrashName[ID](name); ok && trashedID == id && !deletedAt.Before(latestAt) {
+			latest, latestAt = name, deletedAt
+		}
+	}
+
+	if latest == "" {
+		return "", &fs.PathError{Op: "restore", Path: string(id), Err: fs.ErrNotExist}
+	}
+
+	return latest, nil
+}
+
+// PurgeDeleted removes all trashed blobs, which are older than the retention period and returns the amount of purged
+// blobs. Nothing is purged, if the retention period is <= 0.
+func (r *BlobRepository[ID]) PurgeDeleted(ctx context.Context) (int, error) {
+	if r.opts.readOnly {
+		return 0, ReadOnly
+	}
+
+	if r.opts.retention <= 0 {
+		return 0, nil
+	}
+
+	r.trash.mutex.Lock()
+	r.trash.lastPurge = r.opts.now()
+	r.trash.mutex.Unlock()
+
+	trashed, err := r.ListDeleted(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	deadline := r.opts.now().Add(-r.opts.retention)
+	count := 0
+	for _, t := range trashed {
+		if t.DeletedAt.After(deadline) {
+			break // sorted by deletion time
+		}
+
+		if err := Remove(r.fs, trashName(t.ID, t.DeletedAt)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// maybePurge purges the trash, if it has not been purged within the last hour.
+func (r *BlobRepository[ID]) maybePurge(ctx context.Context) {
+	if !r.opts.softDelete || r.opts.retention <= 0 {
+		return
+	}
+
+	r.trash.mutex.Lock()
+	due := r.opts.now().Sub(r.trash.lastPurge) >= purgeThrottle
+	r.trash.mutex.Unlock()
+
+	if due {
+		_, _ = r.PurgeDeleted(ctx) // best effort, next time again
+	}
+}
@@ -0,0 +1,105 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository"
+	"io"
+	"io/fs"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestBlobRepository_SoftDelete(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	repo := must(NewBlobRepository[string](Dir(t.TempDir()), WithSoftDelete(time.Hour), WithClock(clock.Now)))
+
+	for _, name := range []string{"a", "b/c"} {
+		w := must(repo.Write(ctx, name))
+		must(w.Write([]byte(name)))
+		must("", w.Close())
+	}
+
+	must("", repo.Delete(ctx, "b/c"))
+	clock.Advance(time.Minute)
+	must("", repo.DeleteAll(ctx))
+
+	if n := must(repo.Count(ctx)); n != 0 {
+		t.Fatalf("expected 0 but got %v", n)
+	}
+
+	trashed := must(repo.ListDeleted(ctx))
+	if len(trashed) != 2 || trashed[0].ID != "b/c" || trashed[1].ID != "a" {
+		t.Fatalf("unexpected trash %v", trashed)
+	}
+
+	must("", repo.Restore(ctx, "b/c"))
+	r := must(repo.Read(ctx, "b/c"))
+	if buf := must(io.ReadAll(r)); string(buf) != "b/c" {
+		t.Fatalf("unexpected restored content %q", buf)
+	}
+	must("", r.Close())
+
+	if err := repo.Restore(ctx, "b/c"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist but got %v", err)
+	}
+
+	w := must(repo.Write(ctx, "a"))
+	must("", w.Close())
+	if err := repo.Restore(ctx, "a"); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected exist but got %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	if n := must(repo.PurgeDeleted(ctx)); n != 1 {
+		t.Fatalf("expected 1 purged but got %v", n)
+	}
+
+	if trashed := must(repo.ListDeleted(ctx)); len(trashed) != 0 {
+		t.Fatalf("expected empty trash but got %v", trashed)
+	}
+}
+
+func TestRepository_SoftDelete(t *testing.T) {
+	repo := must(NewRepository[string, int](Dir(t.TempDir()), WithSoftDelete(0)))
+	must("", repo.Save(1, "one"))
+	must("", repo.DeleteByID(1))
+
+	if _, err := repo.FindByID(1); !errors.As(err, &repository.EntityNotFoundError{}) {
+		t.Fatalf("expected not found but got %v", err)
+	}
+
+	if trashed := must(repo.ListDeleted()); len(trashed) != 1 || trashed[0].ID != 1 {
+		t.Fatalf("unexpected trash %v", trashed)
+	}
+
+	must("", repo.RestoreDeleted(1))
+	if v := must(repo.FindByID(1)); v != "one" {
+		t.Fatalf("unexpected entity %v", v)
+	}
+
+	if err := repo.RestoreDeleted(2); !errors.As(err, &repository.EntityNotFoundError{}) {
+		t.Fatalf("expected not found but got %v", err)
+	}
+}
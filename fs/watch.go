@@ -0,0 +1,152 @@
+package fs
+
+import (
+	"context"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/internal/notify"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// stamp is used to detect modifications, like make does.
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watcher distributes local changes immediately and detects foreign changes by polling the modification times.
+type watcher[ID Name] struct {
+	hub      notify.Hub[repository.Event[ID]]
+	interval time.Duration
+	mutex    sync.Mutex   // guards known and running and serializes publishing
+	known    map[ID]stamp // known is only valid while running
+	running  bool
+}
+
+// Watch subscribes to all changes until ctx is done. Changes made through this instance are published
+// immediately after committing. Changes made by other processes are detected by polling the modification
+// times in the configured interval, which only happens while at least one subscriber exists. Due to the nature of
+// polling, events are delivered at least once but may be coalesced, e.g. if a file is overwritten
+// multiple times in a single interval.
+func (r *BlobRepository[ID]) Watch(ctx context.Context, opts repository.WatchOptions) (<-chan repository.Event[ID], error) {
+	r.watcher.mutex.Lock()
+	defer r.watcher.mutex.Unlock()
+
+	// subscribe only after a successful scan, so that a failed Watch does not leak the subscriber. Holding the
+	// mutex ensures, that no change is published in between.
+	if !r.watcher.running {
+		known, err := r.scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		r.watcher.known = known
+		r.watcher.running = true
+		go r.poll()
+	}
+
+	return r.watcher.hub.Subscribe(ctx, opts), nil
+}
+
+func (r *BlobRepository[ID]) poll() {
+	ticker := time.NewTicker(r.watcher.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !r.pollOnce() {
+			return
+		}
+	}
+}
+
+// pollOnce compares the current state with the last known state and returns false, if polling
+// shall be stopped, because nobody is listening anymore.
+func (r *BlobRepository[ID]) pollOnce() bool {
+	r.watcher.mutex.Lock()
+	defer r.watcher.mutex.Unlock()
+
+	if r.watcher.hub.Len() == 0 {
+		r.watcher.running = false
+		r.watcher.known = nil
+		return false
+	}
+
+	current, err := r.scan(context.Background())
+	if err != nil {
+		return true // e.g. concurrent removal of a directory, just try again next time
+	}
+
+	for id, s := range current {
+		if old, ok := r.watcher.known[id]; !ok || old != s {
+			r.publish(repository.Saved, id)
+		}
+	}
+
+	for id := range r.watcher.known {
+		if _, ok := current[id]; !ok {
+			r.publish(repository.Deleted, id)
+		}
+	}
+
+	r.watcher.known = current
+
+	return true
+}
+
+// notify publishes a local change and updates the known state, so that the poller does not report it again.
+func (r *BlobRepository[ID]) notify(t repository.EventType, id ID) {
+	r.watcher.mutex.Lock()
+	defer r.watcher.mutex.Unlock()
+
+	if r.watcher.running {
+		switch t {
+		case repository.Saved:
+			if info, err := fs.Stat(r.fs, string(id)); err == nil {
+				s := stamp{modTime: info.ModTime(), size: info.Size()}
+				if old, ok := r.watcher.known[id]; ok && old == s {
+					return // already reported by poller
+				}
+
+				r.watcher.known[id] = s
+			}
+		case repository.Deleted:
+			if _, ok := r.watcher.known[id]; !ok {
+				return // already reported by poller
+			}
+
+			delete(r.watcher.known, id)
+		case repository.Cleared:
+			r.watcher.known = map[ID]stamp{}
+		}
+	}
+
+	r.publish(t, id)
+}
+
+// publish sends the event to all subscribers. The caller must hold the watcher mutex.
+func (r *BlobRepository[ID]) publish(t repository.EventType, id ID) {
+	r.watcher.hub.Publish(func(opts repository.WatchOptions) repository.Event[ID] {
+		return repository.Event[ID]{Type: t, ID: id}
+	})
+}
+
+// scan collects the modification stamps of all blobs.
+func (r *BlobRepository[ID]) scan(ctx context.Context) (map[ID]stamp, error) {
+	res := map[ID]stamp{}
+	err := r.walk(ctx, ".", func(id ID, d fs.DirEntry) error {
+		info, err := d.Info()
+		if err != nil {
+			return nil // removed in the meantime
+		}
+
+		res[id] = stamp{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
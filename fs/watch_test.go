@@ -0,0 +1,126 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBlobRepository_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	repo := must(NewBlobRepository[string](Dir(dir), WithPollInterval(10*time.Millisecond)))
+	events := must(repo.Watch(ctx, repository.WatchOptions{}))
+
+	// local changes
+	w := must(repo.Write(ctx, "local"))
+	must(w.Write([]byte("hello")))
+	must("", w.Close())
+	must("", repo.Delete(ctx, "local"))
+
+	expectEvent(t, events, repository.Saved, "local")
+	expectEvent(t, events, repository.Deleted, "local")
+
+	// foreign changes
+	must("", os.WriteFile(filepath.Join(dir, "foreign"), []byte("world"), 0600))
+	expectEvent(t, events, repository.Saved, "foreign")
+
+	must("", os.Remove(filepath.Join(dir, "foreign")))
+	expectEvent(t, events, repository.Deleted, "foreign")
+
+	must("", repo.DeleteAll(ctx))
+	expectEvent(t, events, repository.Cleared, "")
+}
+
+func expectEvent(t *testing.T, events <-chan repository.Event[string], typ repository.EventType, id string) {
+	t.Helper()
+
+	select {
+	case evt := <-events:
+		if evt.Type != typ || evt.ID != id {
+			t.Fatalf("expected %v %v but got %v %v", typ, id, evt.Type, evt.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for %v %v", typ, id)
+	}
+}
+
+// failingReadDir fails to read directories while failing is set.
+type failingReadDir struct {
+	*MemFS
+	failing int32
+}
+
+func (f *failingReadDir) ReadDir(name string) ([]fs.DirEntry, error) {
+	if atomic.LoadInt32(&f.failing) != 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("injected failure")}
+	}
+
+	return f.MemFS.ReadDir(name)
+}
+
+func TestBlobRepository_WatchScanFailure(t *testing.T) {
+	fsys := &failingReadDir{MemFS: NewMemFS()}
+	repo := must(NewBlobRepository[string](fsys))
+
+	atomic.StoreInt32(&fsys.failing, 1)
+	if _, err := repo.Watch(context.Background(), repository.WatchOptions{}); err == nil {
+		t.Fatal("expected scan failure")
+	}
+
+	if n := repo.watcher.hub.Len(); n != 0 {
+		t.Fatalf("expected no subscribers but got %v", n)
+	}
+
+	atomic.StoreInt32(&fsys.failing, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := must(repo.Watch(ctx, repository.WatchOptions{}))
+	must("", repo.DeleteAll(ctx))
+	expectEvent(t, events, repository.Cleared, "")
+}
+
+func TestBlobRepository_WatchDeleteAllFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fsys := NewMemFS()
+	repo := must(NewBlobRepository[string](fsys, WithPollInterval(time.Hour)))
+	events := must(repo.Watch(ctx, repository.WatchOptions{}))
+
+	for _, id := range []string{"a", "b"} {
+		w := must(repo.Write(ctx, id))
+		must(w.Write([]byte(id)))
+		must("", w.Close())
+		expectEvent(t, events, repository.Saved, id)
+	}
+
+	fsys.Inject(func(op, name string) error {
+		if op == FaultRemove && name == "b" {
+			return errors.New("injected failure")
+		}
+
+		return nil
+	})
+
+	if err := repo.DeleteAll(ctx); err == nil {
+		t.Fatal("expected failure")
+	}
+
+	// only the actually removed blob is reported
+	expectEvent(t, events, repository.Deleted, "a")
+	select {
+	case evt := <-events:
+		t.Fatalf("unexpected event %v %v", evt.Type, evt.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,10 @@
+package repository
+
+import "time"
+
+// Revision is a historic version of an entity, as recorded by repositories which keep a history.
+type Revision[T any] struct {
+	Entity  T         // Entity is the zero value, if Deleted is true.
+	SavedAt time.Time // SavedAt is the time when the entity has been saved or deleted.
+	Deleted bool      // Deleted is true, if the revision denotes the removal of the entity.
+}
@@ -0,0 +1,165 @@
+// Package lock provides the per-ID locks of the repository implementations.
+package lock
+
+import (
+	"context"
+	"github.com/golangee/repository"
+	"sync"
+	"time"
+)
+
+// Table implements repository.Locker. The zero value is ready to use. Like sync.RWMutex, a waiting writer blocks
+// new readers, so that continuous readers cannot starve writers. Hence, read locks must not be acquired recursively.
+type Table[K comparable] struct {
+	mutex sync.Mutex
+	locks map[K]*state
+}
+
+type state struct {
+	readers        int
+	writer         bool
+	waiters        int
+	writersWaiting int           // writersWaiting blocks new readers
+	changed        chan struct{} // changed is closed and replaced whenever the lock is released
+}
+
+// holder is a single acquired lock.
+type holder struct {
+	exclusive bool
+	released  bool
+	expired   bool
+	timer     *time.Timer
+}
+
+func (t *Table[K]) Lock(ctx context.Context, k K) (repository.Unlock, error) {
+	return t.acquire(ctx, k, true, 0)
+}
+
+func (t *Table[K]) RLock(ctx context.Context, k K) (repository.Unlock, error) {
+	return t.acquire(ctx, k, false, 0)
+}
+
+// LockLease acquires an exclusive lock, which is released after the lease. A lease <= 0 never expires.
+func (t *Table[K]) LockLease(ctx context.Context, k K, lease time.Duration) (repository.Unlock, error) {
+	return t.acquire(ctx, k, true, lease)
+}
+
+func (t *Table[K]) acquire(ctx context.Context, k K, exclusive bool, lease time.Duration) (repository.Unlock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	t.mutex.Lock()
+	if t.locks == nil {
+		t.locks = map[K]*state{}
+	}
+
+	s := t.locks[k]
+	if s == nil {
+		s = &state{changed: make(chan struct{})}
+		t.locks[k] = s
+	}
+
+	for s.writer || (exclusive && s.readers > 0) || (!exclusive && s.writersWaiting > 0) {
+		s.waiters++
+		if exclusive {
+			s.writersWaiting++
+		}
+
+		changed := s.changed
+		t.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			t.mutex.Lock()
+			s.waiters--
+			if exclusive {
+				s.writersWaiting--
+				s.wake() // readers may have been blocked by us
+			}
+
+			t.cleanup(k, s)
+			t.mutex.Unlock()
+
+			return nil, ctx.Err()
+		case <-changed:
+		}
+
+		t.mutex.Lock()
+		s.waiters--
+		if exclusive {
+			s.writersWaiting--
+		}
+	}
+
+	if exclusive {
+		s.writer = true
+	} else {
+		s.readers++
+	}
+
+	h := &holder{exclusive: exclusive}
+	if lease > 0 {
+		h.timer = time.AfterFunc(lease, func() {
+			_ = t.release(k, s, h, true)
+		})
+	}
+
+	t.mutex.Unlock()
+
+	return func() error {
+		return t.release(k, s, h, false)
+	}, nil
+}
+
+// release frees the lock of the holder and wakes up all waiters.
+func (t *Table[K]) release(k K, s *state, h *holder, expired bool) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if h.released {
+		if h.expired && !expired {
+			return repository.LeaseExpired
+		}
+
+		return nil
+	}
+
+	h.released = true
+	h.expired = expired
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+
+	if h.exclusive {
+		s.writer = false
+	} else {
+		s.readers--
+	}
+
+	s.wake()
+	t.cleanup(k, s)
+
+	return nil
+}
+
+// wake notifies all waiters about a changed state. The caller must hold the lock.
+func (s *state) wake() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// cleanup removes the unused state. The caller must hold the lock.
+func (t *Table[K]) cleanup(k K, s *state) {
+	if !s.writer && s.readers == 0 && s.waiters == 0 {
+		delete(t.locks, k)
+	}
+}
+
+// Len returns the amount of locked or awaited keys, which is useful for leak tests.
+func (t *Table[K]) Len() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return len(t.locks)
+}
@@ -0,0 +1,122 @@
+// Package notify provides the subscriber management for the change notifications of the repository implementations.
+package notify
+
+import (
+	"context"
+	"github.com/golangee/repository"
+	"sync"
+)
+
+const defaultBuffer = 64
+
+type subscriber[E any] struct {
+	ch      chan E
+	done    <-chan struct{}
+	removed chan struct{} // removed is closed, when the subscriber is removed, e.g. due to an overflow
+	opts    repository.WatchOptions
+}
+
+// Hub distributes events to subscribers with buffered channels. The zero value is ready to use.
+type Hub[E any] struct {
+	mutex sync.Mutex
+	subs  map[*subscriber[E]]struct{}
+}
+
+// Subscribe registers a new subscriber, which is removed and whose channel is closed when ctx is done.
+func (h *Hub[E]) Subscribe(ctx context.Context, opts repository.WatchOptions) <-chan E {
+	if opts.Buffer <= 0 {
+		opts.Buffer = defaultBuffer
+	}
+
+	s := &subscriber[E]{
+		ch:      make(chan E, opts.Buffer),
+		done:    ctx.Done(),
+		removed: make(chan struct{}),
+		opts:    opts,
+	}
+
+	h.mutex.Lock()
+	if h.subs == nil {
+		h.subs = map[*subscriber[E]]struct{}{}
+	}
+	h.subs[s] = struct{}{}
+	h.mutex.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.removed:
+			return
+		}
+
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+
+		h.remove(s)
+	}()
+
+	return s.ch
+}
+
+// Len returns the amount of current subscribers.
+func (h *Hub[E]) Len() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return len(h.subs)
+}
+
+// Publish creates an event for each subscriber and delivers it according to the subscribers overflow policy.
+// The event factory is invoked per subscriber, so that each one owns its event exclusively.
+// Publish returns after all events have been delivered or discarded, thus the order of events is stable.
+func (h *Hub[E]) Publish(event func(opts repository.WatchOptions) E) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for s := range h.subs {
+		e := event(s.opts)
+
+		switch s.opts.Overflow {
+		case repository.OverflowDropNewest:
+			select {
+			case s.ch <- e:
+			default:
+			}
+		case repository.OverflowDropOldest:
+			for {
+				select {
+				case s.ch <- e:
+				default:
+					select {
+					case <-s.ch:
+					default:
+					}
+					continue
+				}
+				break
+			}
+		case repository.OverflowClose:
+			select {
+			case s.ch <- e:
+			default:
+				h.remove(s)
+			}
+		default:
+			select {
+			case s.ch <- e:
+			case <-s.done:
+			}
+		}
+	}
+}
+
+// remove unsubscribes and closes the channel. The caller must hold the mutex.
+func (h *Hub[E]) remove(s *subscriber[E]) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+
+	delete(h.subs, s)
+	close(s.ch)
+	close(s.removed)
+}
@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"context"
+	"github.com/golangee/repository"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestHub_OverflowCloseReleasesSubscriber(t *testing.T) {
+	var hub Hub[int]
+	before := runtime.NumGoroutine()
+
+	// the context is never cancelled, so only the overflow can release the subscribers
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		ch := hub.Subscribe(ctx, repository.WatchOptions{Buffer: 1, Overflow: repository.OverflowClose})
+		hub.Publish(func(repository.WatchOptions) int { return 1 })
+		hub.Publish(func(repository.WatchOptions) int { return 2 })
+
+		for range ch {
+			// drain until closed by the overflow
+		}
+	}
+
+	if n := hub.Len(); n != 0 {
+		t.Fatalf("expected no subscribers but got %v", n)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %v goroutines but got %v", before, runtime.NumGoroutine())
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -32,3 +32,8 @@ func Constructor[T any]() (factory func() T, isPtrType bool) {
 		return reflect.New(reflect.TypeOf(localT).Elem()).Interface().(T)
 	}, isPtr
 }
+
+// TypeName returns the Go type name of T, including pointer types.
+func TypeName[T any]() string {
+	return reflect.TypeOf((*T)(nil)).Elem().String()
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository"
+	"testing"
+	"time"
+)
+
+// blocked is the time to wait, until an acquisition is considered to be blocked.
+const blocked = 20 * time.Millisecond
+
+func tryLock[ID comparable](lock func(ctx context.Context, id ID) (repository.Unlock, error), id ID) (repository.Unlock, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), blocked)
+	defer cancel()
+
+	return lock(ctx, id)
+}
+
+// TestLocker verifies the contract of repository.Locker using two distinct ids.
+func TestLocker[ID comparable](t *testing.T, locker repository.Locker[ID], a, b ID) {
+	t.Helper()
+	ctx := context.Background()
+
+	// exclusive locks exclude each other per id
+	unlock := expect(locker.Lock(ctx, a))
+	if _, err := tryLock(locker.Lock, a); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected blocked lock but got %v", err)
+	}
+
+	if _, err := tryLock(locker.RLock, a); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected blocked read lock but got %v", err)
+	}
+
+	must(expect(tryLock(locker.Lock, b))())
+
+	// a waiter acquires the lock when it is released and a second unlock has no effect
+	acquired := make(chan repository.Unlock)
+	go func() {
+		acquired <- expect(locker.Lock(ctx, a))
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("expected waiting lock")
+	case <-time.After(blocked):
+	}
+
+	must(unlock())
+	unlock = <-acquired
+	must(unlock())
+	must(unlock())
+
+	// shared locks only exclude exclusive ones
+	r1 := expect(tryLock(locker.RLock, a))
+	r2 := expect(tryLock(locker.RLock, a))
+	if _, err := tryLock(locker.Lock, a); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected blocked lock but got %v", err)
+	}
+
+	must(r1())
+	must(r2())
+	must(expect(tryLock(locker.Lock, a))())
+
+	// a waiting writer blocks new readers, so that it cannot be starved
+	r1 = expect(tryLock(locker.RLock, a))
+	go func() {
+		acquired <- expect(locker.Lock(ctx, a))
+	}()
+
+	time.Sleep(blocked)
+	if _, err := tryLock(locker.RLock, a); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected read lock blocked by waiting writer but got %v", err)
+	}
+
+	must(r1())
+	must((<-acquired)())
+
+	// a writer which gives up waiting unblocks the readers
+	r1 = expect(tryLock(locker.RLock, a))
+	if _, err := tryLock(locker.Lock, a); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected blocked lock but got %v", err)
+	}
+
+	must(expect(tryLock(locker.RLock, a))())
+	must(r1())
+
+	// an expired lease releases the lock
+	unlock = expect(locker.LockLease(ctx, a, blocked))
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	next := expect(locker.Lock(waitCtx, a))
+	if err := unlock(); !errors.Is(err, repository.LeaseExpired) {
+		t.Fatalf("expected expired lease but got %v", err)
+	}
+
+	must(next())
+
+	// a released lease does not expire anymore
+	unlock = expect(locker.LockLease(ctx, a, time.Hour))
+	must(unlock())
+
+	// a done context never acquires
+	cancel()
+	if _, err := locker.Lock(waitCtx, b); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled context but got %v", err)
+	}
+}
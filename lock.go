@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// LeaseExpired is returned by an Unlock, if the lock has already been released because its lease expired,
+// so that another caller may have acquired it in the meantime.
+var LeaseExpired = errors.New("lease expired")
+
+// Unlock releases a lock. Calling it more than once has no effect.
+type Unlock func() error
+
+// Locker provides pessimistic per-ID locks to serialize read-modify-write cycles of cooperating callers.
+// The locks are advisory: the repository operations themselves neither acquire nor respect them.
+// Unless documented otherwise by the implementation, the locks only coordinate the callers of the same instance and
+// not other processes.
+type Locker[ID comparable] interface {
+	Lock(ctx context.Context, id ID) (Unlock, error)                           // Lock waits for exclusive access until ctx is done.
+	RLock(ctx context.Context, id ID) (Unlock, error)                          // RLock waits for shared access until ctx is done.
+	LockLease(ctx context.Context, id ID, lease time.Duration) (Unlock, error) // LockLease is like Lock, but releases the lock automatically after the lease.
+}
@@ -0,0 +1,137 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"context"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/iter"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// BlobRepository logs all calls of the backend. Read and Write are logged when the returned stream has been
+// closed, including the duration of the entire transfer and the amount of transferred bytes.
+type BlobRepository[ID comparable] struct {
+	backend repository.BlobRepository[ID]
+	logger  *slog.Logger
+	opts    options
+}
+
+// NewBlobRepository creates a logging decorator for the given backend. If logger is nil, slog.Default is used.
+func NewBlobRepository[ID comparable](backend repository.BlobRepository[ID], logger *slog.Logger, opts ...Option) *BlobRepository[ID] {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &BlobRepository[ID]{
+		backend: backend,
+		logger:  logger,
+		opts:    newOptions(opts),
+	}
+}
+
+func (r *BlobRepository[ID]) Count(ctx context.Context) (int64, error) {
+	c := r.opts.start("Count")
+	n, err := r.backend.Count(ctx)
+	c.attrs = append(c.attrs, slog.Int64("count", n))
+	r.opts.done(ctx, r.logger, c, err)
+
+	return n, err
+}
+
+func (r *BlobRepository[ID]) Delete(ctx context.Context, id ID) error {
+	c := r.opts.start("Delete")
+	r.opts.id(c, id)
+	err := r.backend.Delete(ctx, id)
+	r.opts.done(ctx, r.logger, c, err)
+
+	return err
+}
+
+func (r *BlobRepository[ID]) DeleteAll(ctx context.Context) error {
+	c := r.opts.start("DeleteAll")
+	err := r.backend.DeleteAll(ctx)
+	r.opts.done(ctx, r.logger, c, err)
+
+	return err
+}
+
+func (r *BlobRepository[ID]) Write(ctx context.Context, id ID) (io.WriteCloser, error) {
+	c := r.opts.start("Write")
+	r.opts.id(c, id)
+	w, err := r.backend.Write(ctx, id)
+	if err != nil {
+		r.opts.done(ctx, r.logger, c, err)
+		return nil, err
+	}
+
+	return &writer{stream: stream{ctx: ctx, logger: r.logger, opts: &r.opts, call: c, closer: w}, w: w}, nil
+}
+
+func (r *BlobRepository[ID]) Read(ctx context.Context, id ID) (io.ReadCloser, error) {
+	c := r.opts.start("Read")
+	r.opts.id(c, id)
+	rc, err := r.backend.Read(ctx, id)
+	if err != nil {
+		r.opts.done(ctx, r.logger, c, err)
+		return nil, err
+	}
+
+	return &reader{stream: stream{ctx: ctx, logger: r.logger, opts: &r.opts, call: c, closer: rc}, r: rc}, nil
+}
+
+func (r *BlobRepository[ID]) FindAll(ctx context.Context) (iter.Iterator[ID], error) {
+	c := r.opts.start("FindAll")
+	it, err := r.backend.FindAll(ctx)
+	r.opts.done(ctx, r.logger, c, err)
+
+	return it, err
+}
+
+// stream counts the transferred bytes and logs when closed.
+type stream struct {
+	ctx    context.Context
+	logger *slog.Logger
+	opts   *options
+	call   *call
+	closer io.Closer
+	n      int64
+	once   sync.Once
+	err    error
+}
+
+func (s *stream) Close() error {
+	s.once.Do(func() {
+		s.err = s.closer.Close()
+		s.call.attrs = append(s.call.attrs, slog.Int64("bytes", s.n))
+		s.opts.done(s.ctx, s.logger, s.call, s.err)
+	})
+
+	return s.err
+}
+
+type reader struct {
+	stream
+	r io.Reader
+}
+
+func (s *reader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n += int64(n)
+
+	return n, err
+}
+
+type writer struct {
+	stream
+	w io.Writer
+}
+
+func (s *writer) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,118 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"context"
+	"github.com/golangee/repository"
+	"log/slog"
+)
+
+// Repository logs all calls of the backend. Because CrudRepository methods do not accept a context,
+// records are logged with the context bound by WithContext.
+type Repository[T any, ID comparable] struct {
+	backend repository.CrudRepository[T, ID]
+	logger  *slog.Logger
+	opts    options
+	ctx     context.Context
+}
+
+// NewRepository creates a logging decorator for the given backend. If logger is nil, slog.Default is used.
+func NewRepository[T any, ID comparable](backend repository.CrudRepository[T, ID], logger *slog.Logger, opts ...Option) *Repository[T, ID] {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Repository[T, ID]{
+		backend: backend,
+		logger:  logger,
+		opts:    newOptions(opts),
+		ctx:     context.Background(),
+	}
+}
+
+// WithContext returns a shallow copy which logs with the given context.
+func (r *Repository[T, ID]) WithContext(ctx context.Context) *Repository[T, ID] {
+	c := *r
+	c.ctx = ctx
+
+	return &c
+}
+
+func (r *Repository[T, ID]) Count() (int64, error) {
+	c := r.opts.start("Count")
+	n, err := r.backend.Count()
+	c.attrs = append(c.attrs, slog.Int64("count", n))
+	r.opts.done(r.ctx, r.logger, c, err)
+
+	return n, err
+}
+
+func (r *Repository[T, ID]) DeleteByID(id ID) error {
+	c := r.opts.start("DeleteByID")
+	r.opts.id(c, id)
+	err := r.backend.DeleteByID(id)
+	r.opts.done(r.ctx, r.logger, c, err)
+
+	return err
+}
+
+func (r *Repository[T, ID]) DeleteAll() error {
+	c := r.opts.start("DeleteAll")
+	err := r.backend.DeleteAll()
+	r.opts.done(r.ctx, r.logger, c, err)
+
+	return err
+}
+
+func (r *Repository[T, ID]) Save(id ID, entity T) error {
+	c := r.opts.start("Save")
+	r.opts.id(c, id)
+	err := r.backend.Save(id, entity)
+	r.opts.done(r.ctx, r.logger, c, err)
+
+	return err
+}
+
+// SaveAll logs a single record for the entire batch with the amount of produced entities.
+func (r *Repository[T, ID]) SaveAll(f func() (ID, T, error)) error {
+	c := r.opts.start("SaveAll")
+	count := 0
+	err := r.backend.SaveAll(func() (ID, T, error) {
+		id, entity, err := f()
+		if err == nil {
+			count++
+		}
+
+		return id, entity, err
+	})
+
+	c.attrs = append(c.attrs, slog.Int("count", count))
+	r.opts.done(r.ctx, r.logger, c, err)
+
+	return err
+}
+
+func (r *Repository[T, ID]) FindByID(id ID) (T, error) {
+	c := r.opts.start("FindByID")
+	r.opts.id(c, id)
+	entity, err := r.backend.FindByID(id)
+	r.opts.done(r.ctx, r.logger, c, err)
+
+	return entity, err
+}
+
+// FindAll logs a single record for the entire iteration with the amount of consumed entities.
+func (r *Repository[T, ID]) FindAll(f func(id ID, entity T) error) error {
+	c := r.opts.start("FindAll")
+	count := 0
+	err := r.backend.FindAll(func(id ID, entity T) error {
+		count++
+		return f(id, entity)
+	})
+
+	c.attrs = append(c.attrs, slog.Int("count", count))
+	r.opts.done(r.ctx, r.logger, c, err)
+
+	return err
+}
@@ -0,0 +1,79 @@
+//go:build go1.21
+
+// Package logging provides decorators, which emit a log/slog record for each call of a CrudRepository or
+// BlobRepository. It requires Go 1.21 for log/slog, while the rest of the module supports Go 1.18.
+package logging
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+// call collects the attributes of a single call.
+type call struct {
+	method string
+	start  time.Time
+	attrs  []slog.Attr
+}
+
+func (o *options) start(method string) *call {
+	return &call{method: method, start: o.now()}
+}
+
+// id adds the possibly redacted ID.
+func (o *options) id(c *call, id any) {
+	if o.redact != nil {
+		id = o.redact(id)
+	}
+
+	c.attrs = append(c.attrs, slog.Any("id", id))
+}
+
+// done emits the record, unless it is below the threshold or dropped by sampling.
+func (o *options) done(ctx context.Context, logger *slog.Logger, c *call, err error) {
+	duration := o.now().Sub(c.start)
+	level := o.level.Level()
+	outcome := "ok"
+	switch {
+	case err == nil:
+	case notFound(err):
+		outcome = "not_found"
+	default:
+		outcome = "failed"
+		level = slog.LevelError
+	}
+
+	if o.slow > 0 && duration >= o.slow && level < slog.LevelWarn {
+		level = slog.LevelWarn
+	}
+
+	if level < slog.LevelWarn && o.rate < 1 && rand.Float64() >= o.rate {
+		return
+	}
+
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := append([]slog.Attr{
+		slog.String("repository", o.name),
+		slog.String("method", c.method),
+		slog.Duration("duration", duration),
+		slog.String("outcome", outcome),
+	}, c.attrs...)
+
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+
+	logger.LogAttrs(ctx, level, "repository call", attrs...)
+}
+
+func notFound(err error) bool {
+	var nf interface{ NotFound() bool }
+	return (errors.As(err, &nf) && nf.NotFound()) || errors.Is(err, fs.ErrNotExist)
+}
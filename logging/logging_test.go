@@ -0,0 +1,121 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/golangee/repository/fs"
+	"github.com/golangee/repository/internal/test"
+	"github.com/golangee/repository/mem"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+
+		records = append(records, rec)
+	}
+
+	return records
+}
+
+func TestRepository(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := NewRepository[*test.B, int](mem.NewRepository[*test.B, int](), logger, WithRedaction(HashID))
+	test.Test[*test.B, int](t, test.CreateTestSet3(), repo)
+
+	buf.Reset()
+	if _, err := repo.FindByID(4711); err == nil {
+		t.Fatal("expected not found")
+	}
+
+	records := decode(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("expected a single record but got %v", records)
+	}
+
+	rec := records[0]
+	if rec["method"] != "FindByID" || rec["outcome"] != "not_found" || rec["id"] != HashID(4711) || rec["level"] != "DEBUG" {
+		t.Fatalf("unexpected record %v", rec)
+	}
+}
+
+func TestRepository_Sampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	clock := time.Unix(0, 0)
+	now := func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	repo := NewRepository[string, int](mem.NewRepository[string, int](), logger,
+		WithLevel(slog.LevelInfo), WithSampleRate(0), WithSlowThreshold(time.Minute), WithClock(now))
+
+	for i := 0; i < 10; i++ {
+		if err := repo.Save(i, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if records := decode(t, &buf); len(records) != 0 {
+		t.Fatalf("expected all records to be dropped but got %v", records)
+	}
+
+	repo.opts.slow = time.Second
+	if err := repo.Save(1, "slow"); err != nil {
+		t.Fatal(err)
+	}
+
+	if records := decode(t, &buf); len(records) != 1 || records[0]["level"] != "WARN" {
+		t.Fatalf("expected a slow call but got %v", records)
+	}
+}
+
+func TestBlobRepository(t *testing.T) {
+	ctx := context.Background()
+	blobs, err := fs.NewBlobRepository[string](fs.Dir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo := NewBlobRepository[string](blobs, logger, WithName("blobs"))
+
+	w, err := repo.Write(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(w, "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := decode(t, &buf)
+	if len(records) != 1 || records[0]["repository"] != "blobs" || records[0]["bytes"] != 5.0 || records[0]["id"] != "a" {
+		t.Fatalf("unexpected records %v", records)
+	}
+}
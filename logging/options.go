@@ -0,0 +1,90 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Option configures a decorator.
+type Option func(*options)
+
+type options struct {
+	name   string
+	level  slog.Leveler
+	slow   time.Duration
+	rate   float64
+	redact func(id any) any
+	now    func() time.Time
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		name:  "repository",
+		level: slog.LevelDebug,
+		rate:  1,
+		now:   time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithName sets the repository name, which is logged as repository attribute. Defaults to "repository".
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithLevel sets the level of successful calls and calls which did not find the ID. Calls below the level of
+// the handler are not logged at all. Failed calls are always logged with slog.LevelError. Defaults to slog.LevelDebug.
+func WithLevel(level slog.Leveler) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+// WithSlowThreshold escalates calls to slog.LevelWarn, if they take at least the given duration. A value <= 0
+// disables the escalation, which is the default.
+func WithSlowThreshold(d time.Duration) Option {
+	return func(o *options) {
+		o.slow = d
+	}
+}
+
+// WithSampleRate logs only the given fraction of calls below slog.LevelWarn, e.g. 0.01 logs about every
+// hundredth successful call. Failed and slow calls are never dropped. Defaults to 1.
+func WithSampleRate(rate float64) Option {
+	return func(o *options) {
+		o.rate = rate
+	}
+}
+
+// WithRedaction replaces each logged ID by the result of the given hook, e.g. HashID.
+func WithRedaction(redact func(id any) any) Option {
+	return func(o *options) {
+		o.redact = redact
+	}
+}
+
+// WithClock replaces the clock, which is used to measure the duration.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// HashID is a redaction hook, which logs a short sha256 digest instead of the ID, so that log records of the same
+// ID can still be correlated.
+func HashID(id any) any {
+	sum := sha256.Sum256([]byte(fmt.Sprint(id)))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,528 @@
+package mem
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	rfs "github.com/golangee/repository/fs"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// SyncPolicy defines when the append log is flushed to stable storage using fsync.
+type SyncPolicy time.Duration
+
+const (
+	// SyncAlways flushes the log before each modifying call returns.
+	SyncAlways SyncPolicy = 0
+	// SyncNever leaves flushing to the operating system.
+	SyncNever SyncPolicy = -1
+)
+
+// SyncEvery flushes the log periodically in the background, so at most the modifications of the given interval
+// are lost in case of a power failure.
+func SyncEvery(d time.Duration) SyncPolicy {
+	if d <= 0 {
+		return SyncAlways
+	}
+
+	return SyncPolicy(d)
+}
+
+const (
+	opSave  = "s"
+	opDel   = "d"
+	opClear = "c"
+
+	recordHeaderSize = 8
+)
+
+type logRecord[ID comparable] struct {
+	Op      string          `json:"op"`
+	ID      ID              `json:"id"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Version int             `json:"v,omitempty"`
+	Expires *time.Time      `json:"expires,omitempty"`
+}
+
+// appendLog writes length-prefixed and checksummed records:
+//
+//	uint32 big endian payload length | uint32 big endian crc32 (IEEE) of payload | json payload
+//
+// All methods accept a nil receiver, which means that logging is disabled. The owning repository
+// serializes all calls using its write lock.
+type appendLog[ID comparable] struct {
+	fsys      fs.FS
+	name      string
+	policy    SyncPolicy
+	file      rfs.WriteableFile
+	size      int64 // size is the current length of the log file
+	compacted int64 // compacted is the length after the last compaction
+	minSize   int64
+	dirty     bool // dirty is true, if records have been written since the last sync
+	broken    bool // broken is true, if a write has failed and may have left an incomplete record behind
+}
+
+func (l *appendLog[ID]) save(id ID, e entry) error {
+	if l == nil {
+		return nil
+	}
+
+	rec := logRecord[ID]{Op: opSave, ID: id, Data: e.buf, Version: e.version}
+	if !e.expires.IsZero() {
+		expires := e.expires
+		rec.Expires = &expires
+	}
+
+	return l.append(rec)
+}
+
+func (l *appendLog[ID]) delete(id ID) error {
+	if l == nil {
+		return nil
+	}
+
+	return l.append(logRecord[ID]{Op: opDel, ID: id})
+}
+
+func (l *appendLog[ID]) clear() error {
+	if l == nil {
+		return nil
+	}
+
+	return l.append(logRecord[ID]{Op: opClear})
+}
+
+func (l *appendLog[ID]) append(rec logRecord[ID]) error {
+	if l.file == nil {
+		return fmt.Errorf("cannot append to log: %w", fs.ErrClosed)
+	}
+
+	if l.broken {
+		return fmt.Errorf("cannot append to log: %w", LogBroken)
+	}
+
+	frame, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	n, err := l.file.Write(frame)
+	l.size += int64(n)
+	l.dirty = true
+	if err != nil {
+		// a record following an incomplete one would make the log unreadable, so reject further records until
+		// the log has been rewritten
+		l.broken = true
+		return fmt.Errorf("cannot append to log: %w", err)
+	}
+
+	return nil
+}
+
+// commit flushes the log, if the policy requires to sync on each call.
+func (l *appendLog[ID]) commit() error {
+	if l == nil || l.policy != SyncAlways {
+		return nil
+	}
+
+	return l.sync()
+}
+
+func (l *appendLog[ID]) sync() error {
+	if l == nil || !l.dirty {
+		return nil
+	}
+
+	if syncer, ok := l.file.(rfs.SyncableFile); ok {
+		if err := syncer.Sync(); err != nil {
+			return fmt.Errorf("cannot sync log: %w", err)
+		}
+	}
+
+	l.dirty = false
+
+	return nil
+}
+
+func (l *appendLog[ID]) needsCompaction() bool {
+	if l == nil {
+		return false
+	}
+
+	return l.broken || l.size > l.minSize && l.size > 2*l.compacted
+}
+
+// open opens the log file for appending.
+func (l *appendLog[ID]) open() error {
+	file, err := rfs.OpenFile(l.fsys, l.name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open log: %w", err)
+	}
+
+	w, ok := file.(rfs.WriteableFile)
+	if !ok {
+		_ = file.Close()
+		return rfs.WriteableFileNotSupported
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	l.file = w
+	l.size = info.Size()
+	l.broken = false
+
+	return nil
+}
+
+func (l *appendLog[ID]) close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	err := l.sync()
+	if e := l.file.Close(); err == nil {
+		err = e
+	}
+
+	l.file = nil
+
+	return err
+}
+
+func encodeRecord[ID comparable](rec logRecord[ID]) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	copy(frame[recordHeaderSize:], payload)
+
+	return frame, nil
+}
+
+// LogCorrupted is returned when opening a repository, whose append log contains a corrupted record which is
+// followed by other records. Unlike a torn record at the end of the log, this is not caused by a crash and the
+// log is left untouched, so that the following records are not lost.
+var LogCorrupted = errors.New("append log is corrupted")
+
+// LogBroken is returned by modifications after a failed write to the append log, which may have left an incomplete
+// record behind. The log is rewritten by the next Compact, which is also triggered in the background.
+var LogBroken = errors.New("append log is broken")
+
+// errTornRecord denotes an incomplete or corrupted record at the end of the log, which is expected after a crash.
+var errTornRecord = errors.New("torn record")
+
+// decodeRecord reads the next record. The remaining amount of bytes in the log limits the payload size, so that a
+// corrupted length cannot cause a huge allocation. A record exceeding the log is considered torn, a checksum
+// mismatch or an undecodable payload only if it is the last record. An empty payload is never written, so it
+// denotes a zero filled tail, which a crash may leave behind, e.g. due to preallocated extents.
+func decodeRecord[ID comparable](r io.Reader, remaining int64) (logRecord[ID], int64, error) {
+	var rec logRecord[ID]
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return rec, 0, errTornRecord
+		}
+
+		return rec, 0, err // io.EOF or real error
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[0:4]))
+	if size == 0 {
+		return rec, 0, zeroTail(header[:], r, remaining)
+	}
+
+	if size > remaining-recordHeaderSize {
+		return rec, 0, errTornRecord
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return rec, 0, errTornRecord
+		}
+
+		return rec, 0, err
+	}
+
+	n := recordHeaderSize + size
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		if n == remaining {
+			return rec, 0, errTornRecord
+		}
+
+		return rec, 0, fmt.Errorf("%w: checksum mismatch of record at %d bytes before the end", LogCorrupted, remaining)
+	}
+
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		if n == remaining {
+			return rec, 0, errTornRecord
+		}
+
+		return rec, 0, fmt.Errorf("%w: cannot decode log record: %v", LogCorrupted, err)
+	}
+
+	return rec, n, nil
+}
+
+// zeroTail returns errTornRecord, if the header and the rest of the log only contain zeros and LogCorrupted
+// otherwise.
+func zeroTail(header []byte, r io.Reader, remaining int64) error {
+	buf := make([]byte, 4096)
+	chunk := header
+	for {
+		for _, b := range chunk {
+			if b != 0 {
+				return fmt.Errorf("%w: empty record at %d bytes before the end", LogCorrupted, remaining)
+			}
+		}
+
+		n, err := r.Read(buf)
+		chunk = buf[:n]
+		if err == io.EOF {
+			if n == 0 {
+				return errTornRecord
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// startAppendLog replays the log, opens it for appending and starts the background sync and compaction.
+func (r *Repository[T, ID]) startAppendLog() error {
+	if r.opts.logFS == nil {
+		return nil
+	}
+
+	l := &appendLog[ID]{
+		fsys:    r.opts.logFS,
+		name:    r.opts.logName,
+		policy:  r.opts.logSync,
+		minSize: r.opts.logCompactSize,
+	}
+
+	torn, err := r.replay()
+	if err != nil {
+		return err
+	}
+
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	r.log = l
+	l.compacted = l.size
+
+	if torn {
+		// rewrite without the torn tail, otherwise new records would be appended to garbage
+		if err := r.Compact(); err != nil {
+			_ = l.close()
+			return err
+		}
+	}
+
+	r.compact = make(chan struct{}, 1)
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+
+		var syncTick <-chan time.Time
+		if l.policy > 0 {
+			ticker := time.NewTicker(time.Duration(l.policy))
+			defer ticker.Stop()
+			syncTick = ticker.C
+		}
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-syncTick:
+				r.mutex.Lock()
+				err := l.sync()
+				r.mutex.Unlock()
+				r.background(err)
+			case <-r.compact:
+				r.background(r.Compact())
+			}
+		}
+	}()
+
+	return nil
+}
+
+// replay applies all records of the log and returns true, if the log ends with a torn record. A corrupted record
+// in the middle of the log fails with LogCorrupted.
+func (r *Repository[T, ID]) replay() (bool, error) {
+	file, err := r.opts.logFS.Open(r.opts.logName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("cannot open log: %w", err)
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("cannot stat log: %w", err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	reader := bufio.NewReader(file)
+	now := r.opts.now()
+	remaining := info.Size()
+	for {
+		rec, n, err := decodeRecord[ID](reader, remaining)
+		remaining -= n
+		if err == io.EOF {
+			return false, nil
+		}
+
+		if err == errTornRecord {
+			return true, nil
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		switch rec.Op {
+		case opSave:
+			e := entry{buf: rec.Data, version: rec.Version}
+			if rec.Expires != nil {
+				e.expires = *rec.Expires
+			}
+
+			if e.expired(now) {
+				r.remove(rec.ID)
+			} else {
+				r.insert(rec.ID, e)
+			}
+		case opDel:
+			r.remove(rec.ID)
+		case opClear:
+			r.clear()
+		default:
+			return false, fmt.Errorf("unknown log operation %q", rec.Op)
+		}
+	}
+}
+
+// Compact rewrites the append log from the current state. Writers are blocked while compacting.
+// This is also performed automatically in the background.
+func (r *Repository[T, ID]) Compact() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.log == nil || r.log.file == nil {
+		return nil
+	}
+
+	now := r.opts.now()
+	err := rfs.Write(r.log.fsys, r.log.name, func(w io.Writer) error {
+		// a leading clear record ensures, that a restored snapshot cannot resurrect deleted entities
+		frame, err := encodeRecord(logRecord[ID]{Op: opClear})
+		if err != nil {
+			return err
+		}
+
+		bw := bufio.NewWriter(w)
+		if _, err := bw.Write(frame); err != nil {
+			return err
+		}
+
+		for id, e := range r.store {
+			if e.expired(now) {
+				continue
+			}
+
+			rec := logRecord[ID]{Op: opSave, ID: id, Data: e.buf, Version: e.version}
+			if !e.expires.IsZero() {
+				expires := e.expires
+				rec.Expires = &expires
+			}
+
+			frame, err := encodeRecord(rec)
+			if err != nil {
+				return err
+			}
+
+			if _, err := bw.Write(frame); err != nil {
+				return err
+			}
+		}
+
+		return bw.Flush()
+	})
+
+	if err != nil {
+		return fmt.Errorf("cannot compact log: %w", err)
+	}
+
+	// the old file has been replaced atomically, so continue appending to the new one
+	if err := r.log.close(); err != nil {
+		return err
+	}
+
+	if err := r.log.open(); err != nil {
+		return err
+	}
+
+	r.log.compacted = r.log.size
+
+	return nil
+}
+
+// commit syncs the log according to the policy and triggers a background compaction, if required.
+// The caller must hold the write lock.
+func (r *Repository[T, ID]) commit(err error) error {
+	if e := r.log.commit(); err == nil {
+		err = e
+	}
+
+	if r.log.needsCompaction() {
+		select {
+		case r.compact <- struct{}{}:
+		default:
+		}
+	}
+
+	return err
+}
+
+// background records the first error of a background task.
+func (r *Repository[T, ID]) background(err error) {
+	if err == nil {
+		return
+	}
+
+	r.bgMutex.Lock()
+	defer r.bgMutex.Unlock()
+
+	if r.bgErr == nil {
+		r.bgErr = err
+	}
+}
@@ -0,0 +1,368 @@
+package mem
+
+import (
+	"bytes"
+	"errors"
+	"github.com/golangee/repository/fs"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRepository_AppendLog(t *testing.T) {
+	dir := t.TempDir()
+	open := func() *Repository[string, int] {
+		repo, err := Open[string, int](WithAppendLog(fs.Dir(dir), "repo.log", SyncAlways))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return repo
+	}
+
+	repo := open()
+	for i := 1; i <= 3; i++ {
+		if err := repo.Save(i, "v1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repo.DeleteByID(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Save(3, "v2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.SaveWithTTL(4, "gone", time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a crash while appending a record
+	f, err := os.OpenFile(filepath.Join(dir, "repo.log"), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Write([]byte{0, 0, 1, 0, 1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	repo = open()
+	expectEntries(t, repo, map[int]string{1: "v1", 3: "v2"})
+
+	if err := repo.Save(5, "after crash"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	repo = open()
+	defer repo.Close()
+
+	expectEntries(t, repo, map[int]string{1: "v1", 3: "v2", 5: "after crash"})
+}
+
+func TestRepository_AppendLogCompaction(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := Open[string, int](WithAppendLog(fs.Dir(dir), "repo.log", SyncNever), WithCompactionThreshold(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if err := repo.Save(i%2, "value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repo.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "repo.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size() > 200 {
+		t.Fatalf("expected compacted log but got %v bytes", info.Size())
+	}
+
+	if err := repo.DeleteByID(0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err = Open[string, int](WithAppendLog(fs.Dir(dir), "repo.log", SyncNever))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer repo.Close()
+
+	expectEntries(t, repo, map[int]string{1: "value"})
+}
+
+func TestRepository_AppendLogCorruption(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "repo.log")
+	open := func() (*Repository[string, int], error) {
+		return Open[string, int](WithAppendLog(fs.Dir(dir), "repo.log", SyncAlways))
+	}
+
+	repo, err := open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if err := repo.Save(i, "v1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	valid, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a corrupted record followed by valid ones must not silently discard them
+	corrupted := append([]byte(nil), valid...)
+	corrupted[recordHeaderSize+1] ^= 0xff
+	if err := os.WriteFile(name, corrupted, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := open(); !errors.Is(err, LogCorrupted) {
+		t.Fatalf("expected corrupted log but got %v", err)
+	}
+
+	if buf, err := os.ReadFile(name); err != nil || !bytes.Equal(buf, corrupted) {
+		t.Fatalf("expected untouched log but got %v", err)
+	}
+
+	// a corrupted last record is a torn write
+	corrupted = append([]byte(nil), valid...)
+	corrupted[len(corrupted)-2] ^= 0xff
+	if err := os.WriteFile(name, corrupted, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err = open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectEntries(t, repo, map[int]string{1: "v1", 2: "v1"})
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a length exceeding the log is torn as well and never allocated
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Write([]byte{0x7f, 0xff, 0xff, 0xff, 0, 0, 0, 0, '{'}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err = open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectEntries(t, repo, map[int]string{1: "v1", 2: "v1"})
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a zero filled tail, e.g. preallocated by the filesystem before a crash, is torn as well
+	valid, err = os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(name, append(append([]byte(nil), valid...), make([]byte, 64)...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err = open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectEntries(t, repo, map[int]string{1: "v1", 2: "v1"})
+	if err := repo.Save(3, "v2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err = open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectEntries(t, repo, map[int]string{1: "v1", 2: "v1", 3: "v2"})
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// but zeros followed by other data are not
+	valid, err = os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupted = append(make([]byte, 64), valid...)
+	if err := os.WriteFile(name, corrupted, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := open(); !errors.Is(err, LogCorrupted) {
+		t.Fatalf("expected corrupted log but got %v", err)
+	}
+}
+
+func TestRepository_AppendLogWriteFailure(t *testing.T) {
+	fsys := &shortFS{MemFS: fs.NewMemFS()}
+	open := func() (*Repository[string, int], error) {
+		return Open[string, int](WithAppendLog(fsys, "repo.log", SyncAlways))
+	}
+
+	repo, err := open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Save(1, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a partially written record must not be followed by other records, the failing rewrite keeps the log broken
+	atomic.StoreInt32(&fsys.failing, 1)
+	if err := repo.Save(2, "v1"); !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("expected short write but got %v", err)
+	}
+
+	if err := repo.Save(3, "v1"); !errors.Is(err, LogBroken) {
+		t.Fatalf("expected broken log but got %v", err)
+	}
+
+	atomic.StoreInt32(&fsys.failing, 0)
+	if err := repo.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Save(3, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the background compaction may have failed in the meantime
+	if err := repo.Close(); err != nil && !errors.Is(err, io.ErrShortWrite) {
+		t.Fatal(err)
+	}
+
+	repo, err = open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer repo.Close()
+
+	expectEntries(t, repo, map[int]string{1: "v1", 3: "v1"})
+}
+
+// shortFS writes only half of each buffer, while failing is set.
+type shortFS struct {
+	*fs.MemFS
+	failing int32
+}
+
+func (s *shortFS) OpenFile(name string, flag int, perm iofs.FileMode) (iofs.File, error) {
+	file, err := s.MemFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shortFile{SyncableFile: file.(fs.SyncableFile), fsys: s}, nil
+}
+
+func (s *shortFS) Write(name string, w func(w io.Writer) error) error {
+	if atomic.LoadInt32(&s.failing) != 0 {
+		return io.ErrShortWrite
+	}
+
+	return s.MemFS.Write(name, w)
+}
+
+type shortFile struct {
+	fs.SyncableFile
+	fsys *shortFS
+}
+
+func (f *shortFile) Write(p []byte) (int, error) {
+	if atomic.LoadInt32(&f.fsys.failing) == 0 {
+		return f.SyncableFile.Write(p)
+	}
+
+	n, err := f.SyncableFile.Write(p[:len(p)/2])
+	if err != nil {
+		return n, err
+	}
+
+	return n, io.ErrShortWrite
+}
+
+func expectEntries(t *testing.T, repo *Repository[string, int], expected map[int]string) {
+	t.Helper()
+
+	actual := map[int]string{}
+	if err := repo.FindAll(func(id int, entity string) error {
+		actual[id] = entity
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, actual)
+	}
+
+	for id, v := range expected {
+		if actual[id] != v {
+			t.Fatalf("expected %v but got %v", expected, actual)
+		}
+	}
+}
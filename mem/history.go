@@ -0,0 +1,108 @@
+package mem
+
+import (
+	"github.com/golangee/repository"
+	"time"
+)
+
+type revision struct {
+	entry
+	savedAt time.Time
+	deleted bool
+}
+
+// record appends a revision of the saved entry or a deletion marker, if saved is nil. The caller must hold the
+// write lock.
+func (r *Repository[T, ID]) record(id ID, saved *entry) {
+	if !r.opts.history {
+		return
+	}
+
+	revs := r.history[id]
+	rev := revision{savedAt: r.opts.now()}
+	if saved == nil {
+		if len(revs) == 0 || revs[len(revs)-1].deleted {
+			return // nothing to delete, at least as far as we know
+		}
+
+		rev.deleted = true
+	} else {
+		rev.entry = *saved
+		rev.elem = nil
+	}
+
+	if r.history == nil {
+		r.history = map[ID][]revision{}
+	}
+
+	r.history[id] = r.prune(append(revs, rev))
+}
+
+// prune discards the oldest revisions, which exceed the configured limits. The current revision is always kept.
+func (r *Repository[T, ID]) prune(revs []revision) []revision {
+	if n := r.opts.revisions; n > 0 && len(revs) > n+1 {
+		revs = append(revs[:0:0], revs[len(revs)-n-1:]...)
+	}
+
+	if r.opts.historyRetention > 0 {
+		deadline := r.opts.now().Add(-r.opts.historyRetention)
+		i := 0
+		for i < len(revs)-1 && revs[i].savedAt.Before(deadline) {
+			i++
+		}
+
+		if i > 0 {
+			revs = append(revs[:0:0], revs[i:]...)
+		}
+	}
+
+	return revs
+}
+
+// History returns the recorded revisions of the entity, ordered from the oldest to the current one.
+// This requires WithHistory, otherwise the history is always empty.
+func (r *Repository[T, ID]) History(id ID) ([]repository.Revision[T], error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	revs := r.history[id]
+	res := make([]repository.Revision[T], 0, len(revs))
+	for _, rev := range revs {
+		v := repository.Revision[T]{SavedAt: rev.savedAt, Deleted: rev.deleted}
+		if !rev.deleted {
+			entity, err := r.decode(rev.entry)
+			if err != nil {
+				return nil, err
+			}
+
+			v.Entity = entity
+		}
+
+		res = append(res, v)
+	}
+
+	return res, nil
+}
+
+// FindByIDAt returns the entity as it has been at the given point in time. It fails with an EntityNotFoundError,
+// if the entity did not exist at that time or if the according revision has already been discarded.
+func (r *Repository[T, ID]) FindByIDAt(id ID, at time.Time) (T, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	revs := r.history[id]
+	for i := len(revs) - 1; i >= 0; i-- {
+		if revs[i].savedAt.After(at) {
+			continue
+		}
+
+		if revs[i].deleted {
+			break
+		}
+
+		return r.decode(revs[i].entry)
+	}
+
+	var zero T
+	return zero, repository.EntityNotFoundError{ID: id}
+}
@@ -0,0 +1,47 @@
+package mem
+
+import (
+	"errors"
+	"github.com/golangee/repository"
+	"testing"
+	"time"
+)
+
+func TestRepository_History(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	repo := NewRepository[string, int](WithHistory(0, 2*time.Minute), WithClock(clock.Now))
+	defer repo.Close()
+
+	for _, v := range []string{"a", "b", "c", "d"} {
+		if err := repo.Save(1, v); err != nil {
+			t.Fatal(err)
+		}
+
+		clock.Advance(time.Minute)
+	}
+
+	if err := repo.DeleteAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := repo.History(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != 3 || history[0].Entity != "c" || history[1].Entity != "d" || !history[2].Deleted {
+		t.Fatalf("unexpected history %v", history)
+	}
+
+	if v, err := repo.FindByIDAt(1, time.Unix(1000+2*60+30, 0)); err != nil || v != "c" {
+		t.Fatalf("unexpected entity %v: %v", v, err)
+	}
+
+	if _, err := repo.FindByIDAt(1, clock.Now()); !errors.As(err, &repository.EntityNotFoundError{}) {
+		t.Fatalf("expected deleted entity but got %v", err)
+	}
+
+	if _, err := repo.FindByIDAt(2, clock.Now()); !errors.As(err, &repository.EntityNotFoundError{}) {
+		t.Fatalf("expected unknown entity but got %v", err)
+	}
+}
@@ -1,10 +1,16 @@
 package mem
 
 import (
+	"container/list"
+	"context"
 	"encoding/json"
 	"github.com/golangee/repository"
+	"github.com/golangee/repository/internal/lock"
+	"github.com/golangee/repository/internal/notify"
 	"github.com/golangee/repository/internal/reflect"
 	"io"
+	"sync/atomic"
+	"time"
 
 	"sync"
 )
@@ -14,48 +20,153 @@ import (
 // and no data races when modifying the entities concurrently (just causing ghost updates).
 // This implementation is mostly useful for prototyping and testing.
 type Repository[T any, ID comparable] struct {
+	stats     stats
 	mutex     sync.RWMutex
-	store     map[ID][]byte
+	store     map[ID]entry
+	expiring  int   // expiring is the amount of entries in store with an expiration time
+	size      int64 // size is the sum of all serialized entities
+	lru       *list.List
+	lruMutex  sync.Mutex // lruMutex guards the lru order while holding only the read lock
+	onEvict   func(id ID)
 	factory   func() T
 	isPtrType bool
+	hub       notify.Hub[repository.EntityEvent[T, ID]]
+	opts      options
+	stop      chan struct{} // stop is closed to stop all background goroutines
+	janitor   bool          // janitor is true, if the janitor goroutine has been started
+	closed    bool
+	done      sync.WaitGroup
+
+	generation uint64 // generation is incremented with each modification
+	snapshot   uint64 // snapshot is the generation of the last automatic snapshot
+	bgErr      error  // bgErr is the first error of a background task
+	bgMutex    sync.Mutex
+
+	log     *appendLog[ID] // log is nil, if no append log is configured
+	compact chan struct{}  // compact triggers a background compaction of the log
+
+	trash   map[ID]trashed    // trash contains soft deleted entries, if enabled
+	history map[ID][]revision // history contains the recorded revisions, if enabled
+
+	locks lock.Table[ID] // locks are the advisory locks of the callers
+}
+
+type entry struct {
+	buf     []byte
+	version int           // version is the schema version of buf
+	expires time.Time     // expires is zero, if the entry never expires
+	elem    *list.Element // elem is the position in the lru list, if the repository is bounded
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
 }
 
-func NewRepository[T any, ID comparable]() *Repository[T, ID] {
+// NewRepository is like Open but panics, if the repository cannot be opened. Without any persistence options,
+// this never fails.
+func NewRepository[T any, ID comparable](opts ...Option) *Repository[T, ID] {
+	r, err := Open[T, ID](opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// Open creates a new repository and restores any persistent state, e.g. from an automatic snapshot.
+func Open[T any, ID comparable](opts ...Option) (*Repository[T, ID], error) {
 	fac, ptr := reflect.Constructor[T]()
+	o := newOptions(opts)
 
-	return &Repository[T, ID]{
-		store:     map[ID][]byte{},
+	r := &Repository[T, ID]{
+		store:     map[ID]entry{},
+		lru:       newLRU(o),
 		factory:   fac,
 		isPtrType: ptr,
+		opts:      o,
+		stop:      make(chan struct{}),
+	}
+
+	if err := r.startAutoSnapshot(); err != nil {
+		return nil, err
 	}
+
+	if err := r.startAppendLog(); err != nil {
+		close(r.stop)
+		r.done.Wait()
+		return nil, err
+	}
+
+	return r, nil
 }
 
 func (r *Repository[T, ID]) Count() (int64, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	return int64(len(r.store)), nil
+	if r.expiring == 0 {
+		return int64(len(r.store)), nil
+	}
+
+	now := r.opts.now()
+	count := int64(0)
+	for _, e := range r.store {
+		if !e.expired(now) {
+			count++
+		}
+	}
+
+	return count, nil
 }
 
 func (r *Repository[T, ID]) DeleteByID(id ID) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	delete(r.store, id)
-	return nil
+	if e, ok := r.store[id]; ok {
+		if err := r.log.delete(id); err != nil {
+			return r.commit(err)
+		}
+
+		r.remove(id)
+		if !e.expired(r.opts.now()) {
+			r.moveToTrash(id, e)
+			r.record(id, nil)
+			r.publish(repository.Deleted, id, nil)
+		}
+	}
+
+	return r.commit(nil)
 }
 
 func (r *Repository[T, ID]) DeleteAll() error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	// intentionally releasing old map to also free potential large backing slices
-	r.store = map[ID][]byte{}
+	if err := r.log.clear(); err != nil {
+		return r.commit(err)
+	}
 
-	return nil
+	now := r.opts.now()
+	for id, e := range r.store {
+		if !e.expired(now) {
+			r.moveToTrash(id, e)
+			r.record(id, nil)
+		}
+	}
+
+	r.clear()
+
+	return r.commit(nil)
 }
 
 func (r *Repository[T, ID]) Save(id ID, entity T) error {
+	return r.SaveWithTTL(id, entity, r.opts.ttl)
+}
+
+// SaveWithTTL overwrites the entity identified by its ID, which becomes invisible after the given time-to-live.
+// A ttl <= 0 means that the entity never expires.
+func (r *Repository[T, ID]) SaveWithTTL(id ID, entity T, ttl time.Duration) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -64,8 +175,7 @@ func (r *Repository[T, ID]) Save(id ID, entity T) error {
 		return err
 	}
 
-	r.store[id] = buf
-	return nil
+	return r.commit(r.set(id, buf, ttl))
 }
 
 func (r *Repository[T, ID]) SaveAll(f func() (ID, T, error)) error {
@@ -75,19 +185,21 @@ func (r *Repository[T, ID]) SaveAll(f func() (ID, T, error)) error {
 	for {
 		id, entity, err := f()
 		if err == io.EOF {
-			return nil
+			return r.commit(nil)
 		}
 
 		if err != nil {
-			return err
+			return r.commit(err)
 		}
 
 		buf, err := json.Marshal(entity)
 		if err != nil {
-			return err
+			return r.commit(err)
 		}
 
-		r.store[id] = buf
+		if err := r.set(id, buf, r.opts.ttl); err != nil {
+			return r.commit(err)
+		}
 	}
 }
 
@@ -96,12 +208,16 @@ func (r *Repository[T, ID]) FindByID(id ID) (T, error) {
 	defer r.mutex.RUnlock()
 
 	var entity T
-	buf, ok := r.store[id]
-	if !ok {
+	e, ok := r.store[id]
+	if !ok || e.expired(r.opts.now()) {
+		atomic.AddInt64(&r.stats.misses, 1)
 		return entity, repository.EntityNotFoundError{ID: id}
 	}
 
-	return r.unmarshal(buf)
+	atomic.AddInt64(&r.stats.hits, 1)
+	r.touch(e)
+
+	return r.decode(e)
 }
 
 // FindAll invokes the callback for each entry and transfers the ownership.
@@ -110,8 +226,13 @@ func (r *Repository[T, ID]) FindAll(f func(id ID, entity T) error) error {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	for id, buf := range r.store {
-		entity, err := r.unmarshal(buf)
+	now := r.opts.now()
+	for id, e := range r.store {
+		if e.expired(now) {
+			continue
+		}
+
+		entity, err := r.decode(e)
 		if err != nil {
 			return err
 		}
@@ -124,6 +245,192 @@ func (r *Repository[T, ID]) FindAll(f func(id ID, entity T) error) error {
 	return nil
 }
 
+// DeleteExpired removes all expired entities immediately and returns the amount of reclaimed entries.
+// This is also performed periodically by the background janitor.
+func (r *Repository[T, ID]) DeleteExpired() (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.expiring == 0 {
+		return 0, nil
+	}
+
+	now := r.opts.now()
+	count := 0
+	for id, e := range r.store {
+		if e.expired(now) {
+			r.remove(id)
+			r.publish(repository.Deleted, id, nil)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Close stops the background janitor, writes a final automatic snapshot and closes the append log, if configured.
+// The repository is still usable afterwards, however expired entities are only reclaimed by explicit calls
+// to DeleteExpired and modifications are not persisted anymore. Close returns the first error of any background task.
+func (r *Repository[T, ID]) Close() error {
+	r.mutex.Lock()
+	closing := !r.closed
+	if closing {
+		r.closed = true
+		close(r.stop)
+	}
+	r.mutex.Unlock()
+
+	r.done.Wait()
+
+	if closing {
+		r.autoSnapshot()
+
+		r.mutex.Lock()
+		r.background(r.log.close())
+		r.log = nil
+		r.mutex.Unlock()
+	}
+
+	r.bgMutex.Lock()
+	defer r.bgMutex.Unlock()
+
+	return r.bgErr
+}
+
+// set logs and inserts or replaces the entry with the given time-to-live. The caller must hold the write lock.
+func (r *Repository[T, ID]) set(id ID, buf []byte, ttl time.Duration) error {
+	e := entry{buf: buf, version: r.opts.schema.Current()}
+	if ttl > 0 {
+		e.expires = r.opts.now().Add(ttl)
+	}
+
+	if err := r.log.save(id, e); err != nil {
+		return err
+	}
+
+	r.insert(id, e)
+	r.record(id, &e)
+
+	return nil
+}
+
+// clear removes all entries and publishes the change. The caller must hold the write lock.
+func (r *Repository[T, ID]) clear() {
+	// intentionally releasing old map to also free potential large backing slices
+	r.store = map[ID]entry{}
+	r.generation++
+	r.expiring = 0
+	r.size = 0
+	r.lru = newLRU(r.opts)
+
+	var zero ID
+	r.publish(repository.Cleared, zero, nil)
+}
+
+// insert inserts or replaces the entry and publishes the change. The caller must hold the write lock.
+func (r *Repository[T, ID]) insert(id ID, e entry) {
+	if !e.expires.IsZero() {
+		r.startJanitor()
+	}
+
+	r.remove(id)
+	if r.lru != nil {
+		e.elem = r.lru.PushFront(id)
+	}
+
+	r.store[id] = e
+	r.generation++
+	r.size += int64(len(e.buf))
+	if !e.expires.IsZero() {
+		r.expiring++
+	}
+
+	r.publish(repository.Saved, id, &e)
+	r.evict()
+}
+
+// remove deletes the entry without publishing. The caller must hold the write lock.
+func (r *Repository[T, ID]) remove(id ID) {
+	if old, ok := r.store[id]; ok {
+		if !old.expires.IsZero() {
+			r.expiring--
+		}
+
+		if old.elem != nil {
+			r.lru.Remove(old.elem)
+		}
+
+		r.size -= int64(len(old.buf))
+
+		delete(r.store, id)
+		r.generation++
+	}
+}
+
+// startJanitor lazily starts the background janitor. The caller must hold the write lock.
+func (r *Repository[T, ID]) startJanitor() {
+	if r.janitor || r.closed {
+		return
+	}
+
+	r.janitor = true
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+
+		ticker := time.NewTicker(r.opts.janitorInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				_, _ = r.DeleteExpired()
+				_, _ = r.PurgeDeleted()
+			}
+		}
+	}()
+}
+
+// Watch subscribes to all changes until ctx is done. Events are published in commit order while holding
+// the write lock, so consider the overflow policy carefully. If requested, each Saved event contains a decoded
+// copy of the entity. Expired entities are reported as Deleted when they are reclaimed.
+func (r *Repository[T, ID]) Watch(ctx context.Context, opts repository.WatchOptions) (<-chan repository.EntityEvent[T, ID], error) {
+	return r.hub.Subscribe(ctx, opts), nil
+}
+
+// publish notifies all subscribers. The saved entry is nil for any other event than Saved.
+// The caller must hold the write lock.
+func (r *Repository[T, ID]) publish(t repository.EventType, id ID, saved *entry) {
+	r.hub.Publish(func(opts repository.WatchOptions) repository.EntityEvent[T, ID] {
+		evt := repository.EntityEvent[T, ID]{Event: repository.Event[ID]{Type: t, ID: id}}
+		if opts.WithEntity && saved != nil {
+			if entity, err := r.decode(*saved); err == nil {
+				evt.Entity = entity
+				evt.HasEntity = true
+			}
+		}
+
+		return evt
+	})
+}
+
+// decode upcasts the entry to the current schema version, if required, and unmarshals it.
+func (r *Repository[T, ID]) decode(e entry) (T, error) {
+	if e.version == r.opts.schema.Current() {
+		return r.unmarshal(e.buf)
+	}
+
+	buf, err := r.opts.schema.Upcast(e.version, e.buf)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return r.unmarshal(buf)
+}
+
 func (r *Repository[T, ID]) unmarshal(buf []byte) (T, error) {
 	entity := r.factory()
 	if r.isPtrType {
@@ -0,0 +1,24 @@
+package mem
+
+import (
+	"context"
+	"github.com/golangee/repository"
+	"time"
+)
+
+// Lock acquires an exclusive, advisory lock of the entity, waiting until ctx is done. The lock only coordinates
+// the callers, e.g. to serialize a read-modify-write cycle, and is not respected by any other method.
+func (r *Repository[T, ID]) Lock(ctx context.Context, id ID) (repository.Unlock, error) {
+	return r.locks.Lock(ctx, id)
+}
+
+// RLock acquires a shared, advisory lock of the entity, see also Lock.
+func (r *Repository[T, ID]) RLock(ctx context.Context, id ID) (repository.Unlock, error) {
+	return r.locks.RLock(ctx, id)
+}
+
+// LockLease is like Lock but releases the lock automatically after the lease, so that a hanging caller cannot
+// block others forever. Unlocking an expired lock returns repository.LeaseExpired.
+func (r *Repository[T, ID]) LockLease(ctx context.Context, id ID, lease time.Duration) (repository.Unlock, error) {
+	return r.locks.LockLease(ctx, id, lease)
+}
@@ -0,0 +1,19 @@
+package mem
+
+import (
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/internal/test"
+	"testing"
+)
+
+func TestRepository_Locker(t *testing.T) {
+	repo := NewRepository[string, int]()
+	defer repo.Close()
+
+	var locker repository.Locker[int] = repo
+	test.TestLocker(t, locker, 1, 2)
+
+	if n := repo.locks.Len(); n != 0 {
+		t.Fatalf("expected released locks but got %v", n)
+	}
+}
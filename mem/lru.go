@@ -0,0 +1,86 @@
+package mem
+
+import (
+	"container/list"
+	"github.com/golangee/repository"
+	"sync/atomic"
+)
+
+// Stats contains the cache statistics of a Repository.
+type Stats struct {
+	Hits      int64 // Hits is the amount of successful FindByID calls.
+	Misses    int64 // Misses is the amount of FindByID calls which returned an EntityNotFoundError.
+	Evictions int64 // Evictions is the amount of entries, which have been evicted due to capacity limits.
+	Entries   int64 // Entries is the current amount of stored entries, including expired but not yet reclaimed ones.
+	Bytes     int64 // Bytes is the total size of all serialized entities.
+}
+
+// stats must be the first field of the Repository to guarantee 64 bit alignment for atomic access.
+type stats struct {
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// Stats returns a snapshot of the current statistics.
+func (r *Repository[T, ID]) Stats() Stats {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return Stats{
+		Hits:      atomic.LoadInt64(&r.stats.hits),
+		Misses:    atomic.LoadInt64(&r.stats.misses),
+		Evictions: atomic.LoadInt64(&r.stats.evictions),
+		Entries:   int64(len(r.store)),
+		Bytes:     r.size,
+	}
+}
+
+// OnEvict registers a callback, which is invoked for each entry evicted due to the capacity limits.
+// The callback is invoked while holding the write lock and must not call back into the repository.
+func (r *Repository[T, ID]) OnEvict(f func(id ID)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.onEvict = f
+}
+
+// touch marks the entry as most recently used. The caller must hold at least the read lock.
+func (r *Repository[T, ID]) touch(e entry) {
+	if r.lru == nil {
+		return
+	}
+
+	r.lruMutex.Lock()
+	defer r.lruMutex.Unlock()
+
+	r.lru.MoveToFront(e.elem)
+}
+
+// evict removes the least recently used entries until the capacity limits are satisfied.
+// If a single entry exceeds the byte limit, even the most recently saved entry is evicted.
+// The caller must hold the write lock.
+func (r *Repository[T, ID]) evict() {
+	if r.lru == nil {
+		return
+	}
+
+	for r.lru.Len() > 0 && ((r.opts.maxEntries > 0 && len(r.store) > r.opts.maxEntries) || (r.opts.maxBytes > 0 && r.size > r.opts.maxBytes)) {
+		id := r.lru.Back().Value.(ID)
+		r.background(r.log.delete(id))
+		r.remove(id)
+		atomic.AddInt64(&r.stats.evictions, 1)
+		r.publish(repository.Deleted, id, nil)
+		if r.onEvict != nil {
+			r.onEvict(id)
+		}
+	}
+}
+
+func newLRU(o options) *list.List {
+	if !o.bounded() {
+		return nil
+	}
+
+	return list.New()
+}
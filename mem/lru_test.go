@@ -0,0 +1,67 @@
+package mem
+
+import (
+	"errors"
+	"github.com/golangee/repository"
+	"testing"
+)
+
+func TestRepository_MaxEntries(t *testing.T) {
+	repo := NewRepository[string, int](WithMaxEntries(2))
+
+	var evicted []int
+	repo.OnEvict(func(id int) {
+		evicted = append(evicted, id)
+	})
+
+	for i := 1; i <= 2; i++ {
+		if err := repo.Save(i, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 1 becomes most recently used, so 2 gets evicted
+	if _, err := repo.FindByID(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Save(3, "x"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(evicted) != 1 || evicted[0] != 2 {
+		t.Fatalf("expected [2] evicted but got %v", evicted)
+	}
+
+	if _, err := repo.FindByID(2); !errors.As(err, &repository.EntityNotFoundError{}) {
+		t.Fatalf("expected not found but got %v", err)
+	}
+
+	stats := repo.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 || stats.Entries != 2 || stats.Bytes != 6 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRepository_MaxBytes(t *testing.T) {
+	repo := NewRepository[string, int](WithMaxBytes(10))
+
+	for i := 1; i <= 3; i++ {
+		if err := repo.Save(i, "abc"); err != nil { // 5 bytes serialized
+			t.Fatal(err)
+		}
+	}
+
+	stats := repo.Stats()
+	if stats.Entries != 2 || stats.Bytes != 10 || stats.Evictions != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	if err := repo.Save(4, "this does not fit at all"); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats = repo.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,72 @@
+package mem
+
+import (
+	"sort"
+	"sync"
+)
+
+// Namespaces manages an isolated Repository per tenant, which is created on first use. All tenants share the
+// same options, so persistence options must not be used, because tenants would overwrite each other.
+type Namespaces[T any, ID comparable] struct {
+	mutex   sync.Mutex
+	opts    []Option
+	tenants map[string]*Repository[T, ID]
+}
+
+// NewNamespaces creates an empty set of tenants, which are configured with the given options.
+func NewNamespaces[T any, ID comparable](opts ...Option) *Namespaces[T, ID] {
+	return &Namespaces[T, ID]{opts: opts, tenants: map[string]*Repository[T, ID]{}}
+}
+
+// Tenant returns the repository of the tenant and creates it, if required.
+func (n *Namespaces[T, ID]) Tenant(tenant string) (*Repository[T, ID], error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if r, ok := n.tenants[tenant]; ok {
+		return r, nil
+	}
+
+	r, err := Open[T, ID](n.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	n.tenants[tenant] = r
+
+	return r, nil
+}
+
+// Tenants returns all tenants in lexical order.
+func (n *Namespaces[T, ID]) Tenants() []string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	res := make([]string, 0, len(n.tenants))
+	for tenant := range n.tenants {
+		res = append(res, tenant)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// Drop atomically detaches the tenant, so that the next call to Tenant returns a new empty repository.
+// The detached repository is cleared and closed. Dropping an unknown tenant is not an error.
+func (n *Namespaces[T, ID]) Drop(tenant string) error {
+	n.mutex.Lock()
+	r, ok := n.tenants[tenant]
+	delete(n.tenants, tenant)
+	n.mutex.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if err := r.DeleteAll(); err != nil {
+		return err
+	}
+
+	return r.Close()
+}
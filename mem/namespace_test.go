@@ -0,0 +1,46 @@
+package mem
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamespaces(t *testing.T) {
+	ns := NewNamespaces[string, int]()
+	for _, tenant := range []string{"b", "a"} {
+		r, err := ns.Tenant(tenant)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := r.Save(1, tenant); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, _ := ns.Tenant("a")
+	if err := a.DeleteAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := ns.Tenant("b")
+	if n, _ := b.Count(); n != 1 {
+		t.Fatalf("DeleteAll must be scoped to the tenant, but got %v", n)
+	}
+
+	if tenants := ns.Tenants(); !reflect.DeepEqual(tenants, []string{"a", "b"}) {
+		t.Fatalf("unexpected tenants %v", tenants)
+	}
+
+	if err := ns.Drop("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, _ = ns.Tenant("b"); b == nil {
+		t.Fatal("expected new tenant")
+	}
+
+	if n, _ := b.Count(); n != 0 {
+		t.Fatalf("expected dropped tenant but got %v entries", n)
+	}
+}
@@ -0,0 +1,158 @@
+package mem
+
+import (
+	"github.com/golangee/repository/schema"
+	"io/fs"
+	"time"
+)
+
+// Option configures a Repository.
+type Option func(*options)
+
+type options struct {
+	ttl              time.Duration
+	now              func() time.Time
+	janitorInterval  time.Duration
+	maxEntries       int
+	maxBytes         int64
+	snapshotFS       fs.FS
+	snapshotName     string
+	snapshotInterval time.Duration
+	logFS            fs.FS
+	logName          string
+	logSync          SyncPolicy
+	logCompactSize   int64
+	schema           *schema.Registry
+	softDelete       bool
+	retention        time.Duration
+	history          bool
+	revisions        int
+	historyRetention time.Duration
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		now:             time.Now,
+		janitorInterval: time.Minute,
+		logCompactSize:  4 * 1024 * 1024,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithTTL sets the default time-to-live for all entities saved by Save and SaveAll.
+// A value <= 0 means that entities never expire, which is the default.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithClock replaces the wall clock, which is used to determine the expiration of entities.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithJanitorInterval sets the interval in which the background janitor reclaims expired entities.
+// Defaults to one minute.
+func WithJanitorInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.janitorInterval = d
+		}
+	}
+}
+
+// WithMaxEntries limits the amount of entries. If the limit is exceeded, the least recently used entries are evicted.
+// A value <= 0 means unlimited, which is the default.
+func WithMaxEntries(n int) Option {
+	return func(o *options) {
+		o.maxEntries = n
+	}
+}
+
+// WithMaxBytes limits the total size of all serialized entities. If the limit is exceeded, the least recently used
+// entries are evicted. A value <= 0 means unlimited, which is the default.
+func WithMaxBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBytes = n
+	}
+}
+
+func (o options) bounded() bool {
+	return o.maxEntries > 0 || o.maxBytes > 0
+}
+
+// WithAutoSnapshot restores the named snapshot from fsys when opening the repository and periodically writes a new
+// snapshot in the given interval, if the repository has been modified. A final snapshot is written by Close,
+// which is the only one, if the interval is <= 0.
+// The file system must implement the WriteFS interface of the fs package, which replaces the snapshot atomically
+// so that a crash never leaves a torn snapshot.
+func WithAutoSnapshot(fsys fs.FS, name string, interval time.Duration) Option {
+	return func(o *options) {
+		o.snapshotFS = fsys
+		o.snapshotName = name
+		o.snapshotInterval = interval
+	}
+}
+
+// WithAppendLog makes the repository durable on every write, by appending a checksummed record for each
+// modification to the named log before the modifying call returns. When opening the repository, the log is
+// replayed and a torn record at the end, e.g. due to a crash, is discarded. The log is compacted in the background
+// by rewriting it from the current state. The file system must implement the OpenFileFS and WriteFS interfaces of the
+// fs package. If combined with WithAutoSnapshot, the log is replayed after restoring the snapshot.
+func WithAppendLog(fsys fs.FS, name string, policy SyncPolicy) Option {
+	return func(o *options) {
+		o.logFS = fsys
+		o.logName = name
+		o.logSync = policy
+	}
+}
+
+// WithCompactionThreshold sets the minimum size of the append log in bytes, before it is compacted.
+// Compaction only happens, if the log has grown at least to twice its size after the last compaction.
+// Defaults to 4MiB.
+func WithCompactionThreshold(n int64) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.logCompactSize = n
+		}
+	}
+}
+
+// WithSchema records the current schema version of the registry with each saved entity. Entities saved
+// with an older version are upcasted when they are decoded, see also Repository.Upgrade.
+func WithSchema(reg *schema.Registry) Option {
+	return func(o *options) {
+		o.schema = reg
+	}
+}
+
+// WithSoftDelete keeps deleted entities in a trash instead of discarding them, so that they can be restored.
+// Only the most recently deleted version of each ID is kept. Trashed entities older than the retention period are
+// purged by the background janitor or by calling PurgeDeleted explicitly. A retention <= 0 keeps trashed entities
+// forever. The trash is neither included in snapshots nor in the append log.
+func WithSoftDelete(retention time.Duration) Option {
+	return func(o *options) {
+		o.softDelete = true
+		o.retention = retention
+	}
+}
+
+// WithHistory records a revision of an entity on each save or delete, so that earlier versions can be inspected
+// using History and FindByIDAt. Besides the current revision, at most the given amount of previous revisions is kept
+// and previous revisions older than the retention period are discarded. Values <= 0 mean unlimited. The history
+// is neither accounted by WithMaxBytes nor included in snapshots or in the append log.
+func WithHistory(revisions int, retention time.Duration) Option {
+	return func(o *options) {
+		o.history = true
+		o.revisions = revisions
+		o.historyRetention = retention
+	}
+}
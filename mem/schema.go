@@ -0,0 +1,75 @@
+package mem
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Upgrade rewrites all entities which have been saved with an older schema version into the current version.
+// The write lock is only acquired per entity, so Upgrade can be run in the background while the repository is used.
+// Returns the amount of rewritten entities.
+func (r *Repository[T, ID]) Upgrade(ctx context.Context) (int, error) {
+	current := r.opts.schema.Current()
+
+	var outdated []ID
+	r.mutex.RLock()
+	for id, e := range r.store {
+		if e.version != current {
+			outdated = append(outdated, id)
+		}
+	}
+	r.mutex.RUnlock()
+
+	count := 0
+	for _, id := range outdated {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		upgraded, err := r.upgrade(id, current)
+		if err != nil {
+			return count, err
+		}
+
+		if upgraded {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *Repository[T, ID]) upgrade(id ID, current int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	e, ok := r.store[id]
+	if !ok || e.version == current {
+		return false, nil // deleted or saved concurrently
+	}
+
+	entity, err := r.decode(e)
+	if err != nil {
+		return false, err
+	}
+
+	buf, err := json.Marshal(entity)
+	if err != nil {
+		return false, err
+	}
+
+	e.buf, e.version = buf, current
+	if err := r.log.save(id, e); err != nil {
+		return false, r.commit(err)
+	}
+
+	// replace in place, because this is not a modification which needs to be published or affects the lru order
+	r.size += int64(len(e.buf)) - int64(len(r.store[id].buf))
+	r.store[id] = e
+	r.generation++
+
+	// the upgraded document may be larger than the old one
+	r.evict()
+
+	return true, r.commit(nil)
+}
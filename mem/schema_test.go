@@ -0,0 +1,104 @@
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golangee/repository/fs"
+	"github.com/golangee/repository/schema"
+	"testing"
+)
+
+type personV0 struct {
+	Name string
+}
+
+type personV1 struct {
+	Firstname string
+}
+
+func renameName(doc json.RawMessage) (json.RawMessage, error) {
+	var v map[string]any
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return nil, err
+	}
+
+	v["Firstname"] = v["Name"]
+	delete(v, "Name")
+
+	return json.Marshal(v)
+}
+
+func TestRepository_Schema(t *testing.T) {
+	dir := fs.Dir(t.TempDir())
+	old, err := Open[personV0, int](WithAppendLog(dir, "repo.log", SyncNever))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := old.Save(1, personV0{Name: "otto"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := old.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := schema.NewRegistry(1).Register(0, renameName)
+	repo, err := Open[personV1, int](WithAppendLog(dir, "repo.log", SyncNever), WithSchema(reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer repo.Close()
+
+	if p, err := repo.FindByID(1); err != nil || p.Firstname != "otto" {
+		t.Fatalf("expected upcasted entity but got %v %v", p, err)
+	}
+
+	if n, err := repo.Upgrade(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected 1 upgrade but got %v %v", n, err)
+	}
+
+	if n, err := repo.Upgrade(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected 0 upgrades but got %v %v", n, err)
+	}
+
+	if e := repo.store[1]; e.version != 1 || string(e.buf) != `{"Firstname":"otto"}` {
+		t.Fatalf("unexpected entry %v %s", e.version, e.buf)
+	}
+}
+
+func TestRepository_UpgradeMaxBytes(t *testing.T) {
+	dir := fs.Dir(t.TempDir())
+	old, err := Open[personV0, int](WithAppendLog(dir, "repo.log", SyncNever))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		if err := old.Save(i, personV0{Name: "otto"}); err != nil { // 15 bytes serialized
+			t.Fatal(err)
+		}
+	}
+
+	if err := old.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := schema.NewRegistry(1).Register(0, renameName)
+	repo, err := Open[personV1, int](WithAppendLog(dir, "repo.log", SyncNever), WithSchema(reg), WithMaxBytes(35))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer repo.Close()
+
+	// each upgraded entity grows to 20 bytes, so that both do not fit anymore
+	if n, err := repo.Upgrade(context.Background()); err != nil || n != 2 {
+		t.Fatalf("expected 2 upgrades but got %v %v", n, err)
+	}
+
+	if stats := repo.Stats(); stats.Entries != 1 || stats.Bytes != 20 || stats.Evictions != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,207 @@
+package mem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	rfs "github.com/golangee/repository/fs"
+	"io"
+	"io/fs"
+	"time"
+)
+
+const (
+	snapshotFormat  = "golangee/repository/mem"
+	snapshotVersion = 1
+)
+
+type snapshotHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	Count   int    `json:"count"`
+}
+
+type snapshotEntry[ID comparable] struct {
+	ID      ID              `json:"id"`
+	Data    json.RawMessage `json:"data"`
+	Version int             `json:"v,omitempty"`
+	Expires *time.Time      `json:"expires,omitempty"`
+}
+
+// Snapshot writes a consistent point-in-time image of all entities as a stream of json documents.
+// The read lock is only held to copy the references of the serialized entities, so writers are
+// not blocked by a slow writer.
+func (r *Repository[T, ID]) Snapshot(w io.Writer) error {
+	entries, _ := r.image()
+
+	return writeSnapshot(w, entries)
+}
+
+// Restore replaces all entities by the snapshot read from the reader. Nothing is modified, if the
+// snapshot cannot be decoded. Subscribers are notified with a Cleared event followed by a Saved event for each entity.
+func (r *Repository[T, ID]) Restore(reader io.Reader) error {
+	dec := json.NewDecoder(reader)
+
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("cannot decode snapshot header: %w", err)
+	}
+
+	if header.Format != snapshotFormat || header.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot format %s version %d", header.Format, header.Version)
+	}
+
+	entries := make([]snapshotEntry[ID], 0, header.Count)
+	for i := 0; i < header.Count; i++ {
+		var e snapshotEntry[ID]
+		if err := dec.Decode(&e); err != nil {
+			return fmt.Errorf("cannot decode snapshot entry %d: %w", i, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.log.clear(); err != nil {
+		return r.commit(err)
+	}
+
+	r.clear()
+
+	now := r.opts.now()
+	for _, e := range entries {
+		v := entry{buf: e.Data, version: e.Version}
+		if e.Expires != nil {
+			v.expires = *e.Expires
+		}
+
+		if v.expired(now) {
+			continue
+		}
+
+		if err := r.log.save(e.ID, v); err != nil {
+			return r.commit(err)
+		}
+
+		r.insert(e.ID, v)
+	}
+
+	return r.commit(nil)
+}
+
+// image returns a consistent copy of all not expired entries and the according generation.
+func (r *Repository[T, ID]) image() ([]snapshotEntry[ID], uint64) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	now := r.opts.now()
+	entries := make([]snapshotEntry[ID], 0, len(r.store))
+	for id, e := range r.store {
+		if e.expired(now) {
+			continue
+		}
+
+		se := snapshotEntry[ID]{ID: id, Data: e.buf, Version: e.version}
+		if !e.expires.IsZero() {
+			expires := e.expires
+			se.Expires = &expires
+		}
+
+		entries = append(entries, se)
+	}
+
+	return entries, r.generation
+}
+
+func writeSnapshot[ID comparable](w io.Writer, entries []snapshotEntry[ID]) error {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Format: snapshotFormat, Version: snapshotVersion, Count: len(entries)}); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startAutoSnapshot restores the last snapshot and starts the periodic snapshot goroutine, if configured.
+func (r *Repository[T, ID]) startAutoSnapshot() error {
+	if r.opts.snapshotFS == nil {
+		return nil
+	}
+
+	file, err := r.opts.snapshotFS.Open(r.opts.snapshotName)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// nothing to restore
+	case err != nil:
+		return fmt.Errorf("cannot open snapshot: %w", err)
+	default:
+		err := r.Restore(file)
+		_ = file.Close()
+		if err != nil {
+			return fmt.Errorf("cannot restore snapshot: %w", err)
+		}
+	}
+
+	r.snapshot = r.generation
+
+	if r.opts.snapshotInterval <= 0 {
+		return nil // only written on close
+	}
+
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+
+		ticker := time.NewTicker(r.opts.snapshotInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.autoSnapshot()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// autoSnapshot writes a snapshot, if modified since the last one, and records any failure as background error.
+func (r *Repository[T, ID]) autoSnapshot() {
+	if r.opts.snapshotFS == nil {
+		return
+	}
+
+	r.mutex.RLock()
+	modified := r.generation != r.snapshot
+	r.mutex.RUnlock()
+
+	if !modified {
+		return
+	}
+
+	entries, generation := r.image()
+	err := rfs.Write(r.opts.snapshotFS, r.opts.snapshotName, func(w io.Writer) error {
+		return writeSnapshot(w, entries)
+	})
+
+	if err != nil {
+		r.background(fmt.Errorf("cannot write snapshot: %w", err))
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.snapshot = generation
+}
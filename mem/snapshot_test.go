@@ -0,0 +1,92 @@
+package mem
+
+import (
+	"bytes"
+	"github.com/golangee/repository/fs"
+	"github.com/golangee/repository/internal/test"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRepository_SnapshotRestore(t *testing.T) {
+	repo := NewRepository[*test.B, int]()
+	for _, e := range test.CreateTestSet3() {
+		if err := repo.Save(e.ID, e.Entity); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repo.SaveWithTTL(2, &test.B{ID: "volatile"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := repo.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewRepository[*test.B, int]()
+	if err := restored.Save(3, &test.B{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := restored.Count(); n != 2 {
+		t.Fatalf("expected 2 but got %v", n)
+	}
+
+	if !restored.store[2].expires.Equal(repo.store[2].expires) {
+		t.Fatalf("expected restored expiration time")
+	}
+
+	for _, e := range test.CreateTestSet3() {
+		entity, err := restored.FindByID(e.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(entity, e.Entity) {
+			t.Fatalf("expected %v but got %v", e.Entity, entity)
+		}
+	}
+
+	if err := restored.Restore(bytes.NewBufferString(`{"format":"unknown"}`)); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if n, _ := restored.Count(); n != 2 {
+		t.Fatalf("expected unmodified repository but got %v entries", n)
+	}
+}
+
+func TestRepository_AutoSnapshot(t *testing.T) {
+	dir := fs.Dir(t.TempDir())
+
+	repo, err := Open[string, string](WithAutoSnapshot(dir, "snapshot.json", time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Save("a", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err = Open[string, string](WithAutoSnapshot(dir, "snapshot.json", time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer repo.Close()
+
+	if v, err := repo.FindByID("a"); err != nil || v != "hello" {
+		t.Fatalf("expected hello but got %v %v", v, err)
+	}
+}
@@ -0,0 +1,103 @@
+package mem
+
+import (
+	"fmt"
+	"github.com/golangee/repository"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+type trashed struct {
+	entry
+	deletedAt time.Time
+}
+
+// moveToTrash keeps the removed entry, if soft delete is enabled. The caller must hold the write lock.
+func (r *Repository[T, ID]) moveToTrash(id ID, e entry) {
+	if !r.opts.softDelete {
+		return
+	}
+
+	if r.trash == nil {
+		r.trash = map[ID]trashed{}
+	}
+
+	e.elem = nil
+	r.trash[id] = trashed{entry: e, deletedAt: r.opts.now()}
+
+	if r.opts.retention > 0 {
+		r.startJanitor()
+	}
+}
+
+// RestoreDeleted restores the most recently deleted version of the entity. This requires WithSoftDelete.
+// It fails with an EntityNotFoundError, if no such deleted entity exists or if it has expired in the meantime.
+// If the entity has been saved again, it fails with fs.ErrExist.
+func (r *Repository[T, ID]) RestoreDeleted(id ID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	t, ok := r.trash[id]
+	if !ok {
+		return repository.EntityNotFoundError{ID: id}
+	}
+
+	now := r.opts.now()
+	if t.expired(now) {
+		delete(r.trash, id)
+		return repository.EntityNotFoundError{ID: id}
+	}
+
+	if e, ok := r.store[id]; ok && !e.expired(now) {
+		return fmt.Errorf("cannot restore %v: %w", id, fs.ErrExist)
+	}
+
+	if err := r.log.save(id, t.entry); err != nil {
+		return r.commit(err)
+	}
+
+	delete(r.trash, id)
+	r.insert(id, t.entry)
+	r.record(id, &t.entry)
+
+	return r.commit(nil)
+}
+
+// ListDeleted returns all deleted entities which can still be restored, ordered by deletion time.
+func (r *Repository[T, ID]) ListDeleted() ([]repository.Trashed[ID], error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	now := r.opts.now()
+	res := make([]repository.Trashed[ID], 0, len(r.trash))
+	for id, t := range r.trash {
+		if !t.expired(now) {
+			res = append(res, repository.Trashed[ID]{ID: id, DeletedAt: t.deletedAt})
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].DeletedAt.Before(res[j].DeletedAt)
+	})
+
+	return res, nil
+}
+
+// PurgeDeleted removes all deleted entities, which are older than the retention period or have expired and
+// returns the amount of purged entities. This is also performed periodically by the background janitor.
+func (r *Repository[T, ID]) PurgeDeleted() (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.opts.now()
+	count := 0
+	for id, t := range r.trash {
+		if t.expired(now) || (r.opts.retention > 0 && !now.Before(t.deletedAt.Add(r.opts.retention))) {
+			delete(r.trash, id)
+			count++
+		}
+	}
+
+	return count, nil
+}
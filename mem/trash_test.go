@@ -0,0 +1,68 @@
+package mem
+
+import (
+	"errors"
+	"github.com/golangee/repository"
+	"io/fs"
+	"testing"
+	"time"
+)
+
+func TestRepository_SoftDelete(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	repo := NewRepository[string, int](WithSoftDelete(time.Hour), WithClock(clock.Now), WithJanitorInterval(time.Hour))
+	defer repo.Close()
+
+	for i := 1; i <= 3; i++ {
+		if err := repo.Save(i, "entity"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repo.DeleteByID(1); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(time.Minute)
+	if err := repo.DeleteAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := repo.Count(); n != 0 {
+		t.Fatalf("expected 0 but got %v", n)
+	}
+
+	trashed, err := repo.ListDeleted()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trashed) != 3 || trashed[0].ID != 1 {
+		t.Fatalf("unexpected trash %v", trashed)
+	}
+
+	if err := repo.RestoreDeleted(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := repo.FindByID(1); err != nil || v != "entity" {
+		t.Fatalf("unexpected restored entity %v: %v", v, err)
+	}
+
+	if err := repo.RestoreDeleted(1); !errors.As(err, &repository.EntityNotFoundError{}) {
+		t.Fatalf("expected not found but got %v", err)
+	}
+
+	if err := repo.Save(2, "again"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.RestoreDeleted(2); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected exist but got %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	if n, _ := repo.PurgeDeleted(); n != 2 {
+		t.Fatalf("expected 2 purged but got %v", n)
+	}
+}
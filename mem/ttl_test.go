@@ -0,0 +1,110 @@
+package mem
+
+import (
+	"errors"
+	"github.com/golangee/repository"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestRepository_TTL(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	repo := NewRepository[string, int](WithTTL(time.Minute), WithClock(clock.Now), WithJanitorInterval(time.Hour))
+	defer repo.Close()
+
+	if err := repo.Save(1, "default ttl"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.SaveWithTTL(2, "short ttl", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.SaveWithTTL(3, "no ttl", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(time.Second)
+
+	if _, err := repo.FindByID(2); !errors.As(err, &repository.EntityNotFoundError{}) {
+		t.Fatalf("expected not found but got %v", err)
+	}
+
+	if n, _ := repo.Count(); n != 2 {
+		t.Fatalf("expected 2 but got %v", n)
+	}
+
+	clock.Advance(time.Minute)
+
+	var ids []int
+	if err := repo.FindAll(func(id int, entity string) error {
+		ids = append(ids, id)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 1 || ids[0] != 3 {
+		t.Fatalf("expected [3] but got %v", ids)
+	}
+
+	if n, _ := repo.DeleteExpired(); n != 2 {
+		t.Fatalf("expected 2 reclaimed entries but got %v", n)
+	}
+
+	if len(repo.store) != 1 || repo.expiring != 0 {
+		t.Fatalf("expected reclaimed store but got %v entries", len(repo.store))
+	}
+}
+
+func TestRepository_Janitor(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	repo := NewRepository[string, int](WithClock(clock.Now), WithJanitorInterval(time.Millisecond))
+
+	if err := repo.SaveWithTTL(1, "a", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(time.Second)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		repo.mutex.RLock()
+		n := len(repo.store)
+		repo.mutex.RUnlock()
+
+		if n == 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("janitor did not reclaim the entry")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,88 @@
+package mem
+
+import (
+	"context"
+	"github.com/golangee/repository"
+	"testing"
+)
+
+func TestRepository_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	repo := NewRepository[*person, string]()
+
+	events, err := repo.Watch(ctx, repository.WatchOptions{WithEntity: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Save("1", &person{Name: "otto"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.DeleteByID("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.DeleteByID("unknown"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.DeleteAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	evt := <-events
+	if evt.Type != repository.Saved || evt.ID != "1" || !evt.HasEntity || evt.Entity.Name != "otto" {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+
+	if evt = <-events; evt.Type != repository.Deleted || evt.ID != "1" || evt.HasEntity {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+
+	if evt = <-events; evt.Type != repository.Cleared {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+
+	cancel()
+	for range events {
+		// drain until closed by cancellation
+	}
+}
+
+func TestRepository_WatchOverflow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := NewRepository[string, int]()
+	oldest, _ := repo.Watch(ctx, repository.WatchOptions{Buffer: 2, Overflow: repository.OverflowDropOldest})
+	newest, _ := repo.Watch(ctx, repository.WatchOptions{Buffer: 2, Overflow: repository.OverflowDropNewest})
+	closing, _ := repo.Watch(ctx, repository.WatchOptions{Buffer: 2, Overflow: repository.OverflowClose})
+
+	for i := 1; i <= 3; i++ {
+		if err := repo.Save(i, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if a, b := (<-oldest).ID, (<-oldest).ID; a != 2 || b != 3 {
+		t.Fatalf("expected 2,3 but got %v,%v", a, b)
+	}
+
+	if a, b := (<-newest).ID, (<-newest).ID; a != 1 || b != 2 {
+		t.Fatalf("expected 1,2 but got %v,%v", a, b)
+	}
+
+	n := 0
+	for range closing {
+		n++
+	}
+
+	if n != 2 {
+		t.Fatalf("expected 2 events before close but got %v", n)
+	}
+}
+
+type person struct {
+	Name string
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/iter"
+	"io"
+	"sync"
+)
+
+// BlobRepository instruments all calls of the backend. Read and Write are measured until the returned stream
+// has been closed, including the amount of transferred bytes.
+type BlobRepository[ID comparable] struct {
+	backend repository.BlobRepository[ID]
+	opts    options
+}
+
+// NewBlobRepository creates an instrumenting decorator for the given backend, which reports into m.
+func NewBlobRepository[ID comparable](backend repository.BlobRepository[ID], m Metrics, opts ...Option) *BlobRepository[ID] {
+	return &BlobRepository[ID]{
+		backend: backend,
+		opts:    newOptions(m, opts),
+	}
+}
+
+func (r *BlobRepository[ID]) Count(ctx context.Context) (int64, error) {
+	ctx, c := r.opts.start(ctx, "Count")
+	n, err := r.backend.Count(ctx)
+	r.opts.done(c, err)
+
+	return n, err
+}
+
+func (r *BlobRepository[ID]) Delete(ctx context.Context, id ID) error {
+	ctx, c := r.opts.start(ctx, "Delete")
+	err := r.backend.Delete(ctx, id)
+	r.opts.done(c, err)
+
+	return err
+}
+
+func (r *BlobRepository[ID]) DeleteAll(ctx context.Context) error {
+	ctx, c := r.opts.start(ctx, "DeleteAll")
+	err := r.backend.DeleteAll(ctx)
+	r.opts.done(c, err)
+
+	return err
+}
+
+func (r *BlobRepository[ID]) Write(ctx context.Context, id ID) (io.WriteCloser, error) {
+	ctx, c := r.opts.start(ctx, "Write")
+	w, err := r.backend.Write(ctx, id)
+	if err != nil {
+		r.opts.done(c, err)
+		return nil, err
+	}
+
+	return &writer{stream: stream{opts: &r.opts, call: c, closer: w}, w: w}, nil
+}
+
+func (r *BlobRepository[ID]) Read(ctx context.Context, id ID) (io.ReadCloser, error) {
+	ctx, c := r.opts.start(ctx, "Read")
+	rc, err := r.backend.Read(ctx, id)
+	if err != nil {
+		r.opts.done(c, err)
+		return nil, err
+	}
+
+	return &reader{stream: stream{opts: &r.opts, call: c, closer: rc}, r: rc}, nil
+}
+
+func (r *BlobRepository[ID]) FindAll(ctx context.Context) (iter.Iterator[ID], error) {
+	ctx, c := r.opts.start(ctx, "FindAll")
+	it, err := r.backend.FindAll(ctx)
+	r.opts.done(c, err)
+
+	return it, err
+}
+
+// stream counts the transferred bytes and reports when closed.
+type stream struct {
+	opts   *options
+	call   *call
+	closer io.Closer
+	n      int64
+	once   sync.Once
+	err    error
+}
+
+func (s *stream) Close() error {
+	s.once.Do(func() {
+		s.err = s.closer.Close()
+		s.opts.metrics.ObserveBytes(s.call.repository, s.call.method, s.n)
+		s.opts.done(s.call, s.err)
+	})
+
+	return s.err
+}
+
+type reader struct {
+	stream
+	r io.Reader
+}
+
+func (s *reader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n += int64(n)
+
+	return n, err
+}
+
+type writer struct {
+	stream
+	w io.Writer
+}
+
+func (s *writer) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"github.com/golangee/repository"
+)
+
+// Repository instruments all calls of the backend. Because CrudRepository methods do not accept a context,
+// span hooks receive the context bound by WithContext.
+type Repository[T any, ID comparable] struct {
+	backend repository.CrudRepository[T, ID]
+	opts    options
+	ctx     context.Context
+}
+
+// NewRepository creates an instrumenting decorator for the given backend, which reports into m.
+func NewRepository[T any, ID comparable](backend repository.CrudRepository[T, ID], m Metrics, opts ...Option) *Repository[T, ID] {
+	return &Repository[T, ID]{
+		backend: backend,
+		opts:    newOptions(m, opts),
+		ctx:     context.Background(),
+	}
+}
+
+// WithContext returns a shallow copy which passes the given context to the span hook.
+func (r *Repository[T, ID]) WithContext(ctx context.Context) *Repository[T, ID] {
+	c := *r
+	c.ctx = ctx
+
+	return &c
+}
+
+func (r *Repository[T, ID]) Count() (int64, error) {
+	_, c := r.opts.start(r.ctx, "Count")
+	n, err := r.backend.Count()
+	r.opts.done(c, err)
+
+	return n, err
+}
+
+func (r *Repository[T, ID]) DeleteByID(id ID) error {
+	_, c := r.opts.start(r.ctx, "DeleteByID")
+	err := r.backend.DeleteByID(id)
+	r.opts.done(c, err)
+
+	return err
+}
+
+func (r *Repository[T, ID]) DeleteAll() error {
+	_, c := r.opts.start(r.ctx, "DeleteAll")
+	err := r.backend.DeleteAll()
+	r.opts.done(c, err)
+
+	return err
+}
+
+func (r *Repository[T, ID]) Save(id ID, entity T) error {
+	_, c := r.opts.start(r.ctx, "Save")
+	err := r.backend.Save(id, entity)
+	r.opts.done(c, err)
+
+	return err
+}
+
+// SaveAll measures the entire batch including the time spent in the producer.
+func (r *Repository[T, ID]) SaveAll(f func() (ID, T, error)) error {
+	_, c := r.opts.start(r.ctx, "SaveAll")
+	err := r.backend.SaveAll(f)
+	r.opts.done(c, err)
+
+	return err
+}
+
+func (r *Repository[T, ID]) FindByID(id ID) (T, error) {
+	_, c := r.opts.start(r.ctx, "FindByID")
+	entity, err := r.backend.FindByID(id)
+	r.opts.done(c, err)
+
+	return entity, err
+}
+
+// FindAll measures the entire iteration including the time spent in the consumer.
+func (r *Repository[T, ID]) FindAll(f func(id ID, entity T) error) error {
+	_, c := r.opts.start(r.ctx, "FindAll")
+	err := r.backend.FindAll(f)
+	r.opts.done(c, err)
+
+	return err
+}
@@ -0,0 +1,84 @@
+// Package metrics provides instrumentation decorators, which measure calls, errors, latencies and transferred bytes
+// of a CrudRepository or BlobRepository and optionally start tracing spans.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// Outcome classifies the result of a call.
+type Outcome int
+
+const (
+	OK       Outcome = iota // OK denotes a successful call.
+	NotFound                // NotFound denotes a call which failed with an EntityNotFoundError or fs.ErrNotExist.
+	Failed                  // Failed denotes a call which failed with any other error.
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OK:
+		return "ok"
+	case NotFound:
+		return "not_found"
+	case Failed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// outcome classifies the error.
+func outcome(err error) Outcome {
+	if err == nil {
+		return OK
+	}
+
+	var nf interface{ NotFound() bool }
+	if (errors.As(err, &nf) && nf.NotFound()) || errors.Is(err, fs.ErrNotExist) {
+		return NotFound
+	}
+
+	return Failed
+}
+
+// Metrics receives the measurements of a decorator and must be safe for concurrent use. The repository is
+// the name configured by WithName and the method is the name of the called method, e.g. FindByID.
+type Metrics interface {
+	// ObserveCall is invoked after each call. For streamed blobs, the latency includes the entire transfer
+	// until the stream has been closed.
+	ObserveCall(repository, method string, outcome Outcome, latency time.Duration)
+	// ObserveBytes is invoked after a blob stream has been closed with the amount of transferred bytes.
+	ObserveBytes(repository, method string, n int64)
+}
+
+// SpanHook is invoked at the start of each call and returns a context for the backend, which may carry a span,
+// and a function to end the span with the result of the call.
+type SpanHook func(ctx context.Context, repository, method string) (context.Context, func(err error))
+
+// call measures a single call.
+type call struct {
+	repository string
+	method     string
+	start      time.Time
+	end        func(err error)
+}
+
+func (o *options) start(ctx context.Context, method string) (context.Context, *call) {
+	c := &call{repository: o.name, method: method, start: o.now()}
+	if o.span != nil {
+		ctx, c.end = o.span(ctx, o.name, method)
+	}
+
+	return ctx, c
+}
+
+func (o *options) done(c *call, err error) {
+	o.metrics.ObserveCall(c.repository, c.method, outcome(err), o.now().Sub(c.start))
+	if c.end != nil {
+		c.end(err)
+	}
+}
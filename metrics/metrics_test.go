@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"github.com/golangee/repository/fs"
+	"github.com/golangee/repository/internal/test"
+	"github.com/golangee/repository/mem"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRepository(t *testing.T) {
+	m := &Registry{}
+	repo := NewRepository[*test.B, int](mem.NewRepository[*test.B, int](), m, WithName("people"))
+	test.Test[*test.B, int](t, test.CreateTestSet3(), repo)
+
+	if _, err := repo.FindByID(4711); err == nil {
+		t.Fatal("expected not found")
+	}
+
+	stats := m.Snapshot()["people.FindByID"]
+	if stats.Calls == 0 || stats.NotFound == 0 || stats.Errors != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	sum := int64(0)
+	for _, n := range stats.Histogram {
+		sum += n
+	}
+
+	if sum != stats.Calls {
+		t.Fatalf("histogram does not match calls: %+v", stats)
+	}
+
+	expvar.Publish("repository-metrics-test", m)
+	var exported map[string]Stats
+	if err := json.Unmarshal([]byte(expvar.Get("repository-metrics-test").String()), &exported); err != nil {
+		t.Fatal(err)
+	}
+
+	if exported["people.FindByID"].Calls != stats.Calls {
+		t.Fatalf("unexpected export %+v", exported)
+	}
+}
+
+func TestBlobRepository(t *testing.T) {
+	ctx := context.Background()
+	blobs, err := fs.NewBlobRepository[string](fs.Dir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var spans []string
+	var ended []error
+	hook := func(ctx context.Context, repository, method string) (context.Context, func(err error)) {
+		spans = append(spans, repository+"."+method)
+		return ctx, func(err error) {
+			ended = append(ended, err)
+		}
+	}
+
+	clock := time.Unix(0, 0)
+	now := func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	m := &Registry{Buckets: []time.Duration{time.Millisecond, 2 * time.Millisecond}}
+	repo := NewBlobRepository[string](blobs, m, WithName("blobs"), WithSpanHook(hook), WithClock(now))
+
+	w, err := repo.Write(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(w, "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := repo.Read(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Read(ctx, "missing"); err == nil {
+		t.Fatal("expected not found")
+	}
+
+	snapshot := m.Snapshot()
+	if s := snapshot["blobs.Write"]; s.Bytes != 5 || s.Calls != 1 || s.Histogram[0] != 1 {
+		t.Fatalf("unexpected write stats %+v", s)
+	}
+
+	if s := snapshot["blobs.Read"]; s.Bytes != 5 || s.Calls != 2 || s.NotFound != 1 {
+		t.Fatalf("unexpected read stats %+v", s)
+	}
+
+	if len(spans) != 3 || len(ended) != 3 || spans[0] != "blobs.Write" || ended[2] == nil {
+		t.Fatalf("unexpected spans %v: %v", spans, ended)
+	}
+}
+
+func TestRegistry_Buckets(t *testing.T) {
+	bounds := []time.Duration{time.Millisecond, 2 * time.Millisecond}
+	m := &Registry{Buckets: bounds}
+	m.ObserveCall("repo", "Save", OK, time.Millisecond)
+
+	// changes after the first observation must neither be used nor cause out of range accesses
+	bounds[0] = time.Hour
+	m.Buckets = []time.Duration{time.Millisecond}
+	m.ObserveCall("repo", "Save", OK, time.Second)
+	m.ObserveCall("repo", "Delete", OK, time.Second)
+
+	snapshot := m.Snapshot()
+	if h := snapshot["repo.Save"].Histogram; len(h) != 3 || h[0] != 1 || h[2] != 1 {
+		t.Fatalf("unexpected histogram %v", h)
+	}
+
+	if h := snapshot["repo.Delete"].Histogram; len(h) != 3 || h[2] != 1 {
+		t.Fatalf("unexpected histogram %v", h)
+	}
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import "time"
+
+// Option configures a decorator.
+type Option func(*options)
+
+type options struct {
+	name    string
+	metrics Metrics
+	span    SpanHook
+	now     func() time.Time
+}
+
+func newOptions(m Metrics, opts []Option) options {
+	o := options{
+		name:    "repository",
+		metrics: m,
+		now:     time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithName sets the repository name, which is reported to Metrics and SpanHook, so that multiple repositories
+// can share a single Metrics instance. Defaults to "repository".
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithSpanHook installs a hook to start a tracing span for each call.
+func WithSpanHook(hook SpanHook) Option {
+	return func(o *options) {
+		o.span = hook
+	}
+}
+
+// WithClock replaces the clock, which is used to measure the latency.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
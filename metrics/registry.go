@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds of the latency histogram, ranging from 100µs to 10s.
+var DefaultBuckets = []time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Registry is the in-memory implementation of Metrics. It implements expvar.Var, so that it can be exported
+// by expvar.Publish. The zero value is ready to use with the DefaultBuckets.
+type Registry struct {
+	// Buckets are the sorted upper bounds of the latency histogram. They are copied at the first observation,
+	// later changes are ignored.
+	Buckets []time.Duration
+	bounds  []time.Duration // bounds is the copy of Buckets in use
+	once    sync.Once
+	mutex   sync.RWMutex
+	methods map[methodKey]*methodStats
+}
+
+type methodKey struct {
+	repository string
+	method     string
+}
+
+type methodStats struct {
+	calls    int64
+	notFound int64
+	errors   int64
+	bytes    int64
+	nanos    int64
+	buckets  []int64 // buckets has an additional last bucket for all values above the largest bound
+}
+
+// Stats is a snapshot of the measurements of a single method.
+type Stats struct {
+	Calls    int64         `json:"calls"`
+	NotFound int64         `json:"not_found"` // NotFound counts the calls with the NotFound outcome.
+	Errors   int64         `json:"errors"`    // Errors counts the calls with the Failed outcome.
+	Bytes    int64         `json:"bytes"`     // Bytes is the sum of all transferred blob bytes.
+	Latency  time.Duration `json:"latency"`   // Latency is the sum of all latencies.
+	// Histogram contains the amount of calls per latency bucket. The last bucket counts all calls above
+	// the largest bound.
+	Histogram []int64 `json:"histogram"`
+}
+
+// ObserveCall implements Metrics.
+func (r *Registry) ObserveCall(repository, method string, outcome Outcome, latency time.Duration) {
+	s := r.stats(repository, method)
+	atomic.AddInt64(&s.calls, 1)
+	atomic.AddInt64(&s.nanos, int64(latency))
+	switch outcome {
+	case NotFound:
+		atomic.AddInt64(&s.notFound, 1)
+	case Failed:
+		atomic.AddInt64(&s.errors, 1)
+	}
+
+	buckets := r.buckets()
+	i := sort.Search(len(buckets), func(i int) bool { return latency <= buckets[i] })
+	atomic.AddInt64(&s.buckets[i], 1)
+}
+
+// ObserveBytes implements Metrics.
+func (r *Registry) ObserveBytes(repository, method string, n int64) {
+	atomic.AddInt64(&r.stats(repository, method).bytes, n)
+}
+
+// Snapshot returns the measurements of all methods keyed by "<repository>.<method>".
+func (r *Registry) Snapshot() map[string]Stats {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	res := make(map[string]Stats, len(r.methods))
+	for k, s := range r.methods {
+		stats := Stats{
+			Calls:     atomic.LoadInt64(&s.calls),
+			NotFound:  atomic.LoadInt64(&s.notFound),
+			Errors:    atomic.LoadInt64(&s.errors),
+			Bytes:     atomic.LoadInt64(&s.bytes),
+			Latency:   time.Duration(atomic.LoadInt64(&s.nanos)),
+			Histogram: make([]int64, len(s.buckets)),
+		}
+
+		for i := range s.buckets {
+			stats.Histogram[i] = atomic.LoadInt64(&s.buckets[i])
+		}
+
+		res[k.repository+"."+k.method] = stats
+	}
+
+	return res
+}
+
+// String returns the json encoded Snapshot and implements expvar.Var.
+func (r *Registry) String() string {
+	buf, err := json.Marshal(r.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+
+	return string(buf)
+}
+
+func (r *Registry) buckets() []time.Duration {
+	r.once.Do(func() {
+		bounds := r.Buckets
+		if bounds == nil {
+			bounds = DefaultBuckets
+		}
+
+		r.bounds = append([]time.Duration(nil), bounds...)
+	})
+
+	return r.bounds
+}
+
+func (r *Registry) stats(repository, method string) *methodStats {
+	k := methodKey{repository: repository, method: method}
+	r.mutex.RLock()
+	s := r.methods[k]
+	r.mutex.RUnlock()
+
+	if s != nil {
+		return s
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if s = r.methods[k]; s == nil {
+		if r.methods == nil {
+			r.methods = map[methodKey]*methodStats{}
+		}
+
+		s = &methodStats{buckets: make([]int64, len(r.buckets())+1)}
+		r.methods[k] = s
+	}
+
+	return s
+}
@@ -0,0 +1,164 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/iter"
+	"io"
+	"io/fs"
+)
+
+// CopyBlobs writes all blobs from src into dst, overwriting any existing blob.
+func CopyBlobs[ID comparable](ctx context.Context, src, dst repository.BlobRepository[ID], opts Options) (Report[ID], error) {
+	return syncBlobs(ctx, src, dst, opts, false)
+}
+
+// SyncBlobs writes only the blobs from src into dst, which are missing or whose sha256 checksum differs.
+func SyncBlobs[ID comparable](ctx context.Context, src, dst repository.BlobRepository[ID], opts Options) (Report[ID], error) {
+	return syncBlobs(ctx, src, dst, opts, true)
+}
+
+func syncBlobs[ID comparable](ctx context.Context, src, dst repository.BlobRepository[ID], opts Options, incremental bool) (Report[ID], error) {
+	return run(ctx, opts, func(ctx context.Context, emit func(task[ID]) error) error {
+		ids, err := src.FindAll(ctx)
+		if err != nil {
+			return err
+		}
+
+		seen := map[ID]struct{}{}
+		if err := iter.Walk(ids, func(id ID) error {
+			seen[id] = struct{}{}
+			return emit(func(ctx context.Context) (ID, kind, error) {
+				return syncBlob(ctx, src, dst, id, incremental, opts.DryRun)
+			})
+		}); err != nil {
+			return err
+		}
+
+		if !opts.Prune {
+			return nil
+		}
+
+		dstIDs, err := dst.FindAll(ctx)
+		if err != nil {
+			return err
+		}
+
+		all, err := iter.Collect(dstIDs)
+		if err != nil {
+			return err
+		}
+
+		return prune(emit, seen, all, opts.DryRun, func(id ID) error {
+			return dst.Delete(ctx, id)
+		})
+	})
+}
+
+func syncBlob[ID comparable](ctx context.Context, src, dst repository.BlobRepository[ID], id ID, incremental, dryRun bool) (ID, kind, error) {
+	// only an incremental sync needs the content, otherwise the existence decides between created and updated
+	k := created
+	var dstSum []byte
+	var err error
+	if incremental {
+		dstSum, err = checksum(ctx, dst, id)
+	} else {
+		err = exists(ctx, dst, id)
+	}
+
+	switch {
+	case err == nil:
+		k = updated
+		if incremental {
+			srcSum, err := checksum(ctx, src, id)
+			if err != nil {
+				return id, k, err
+			}
+
+			if bytes.Equal(srcSum, dstSum) {
+				return id, unchanged, nil
+			}
+		}
+	case !notFound(err):
+		return id, k, err
+	}
+
+	if dryRun {
+		return id, k, nil
+	}
+
+	return id, k, copyBlob(ctx, src, dst, id)
+}
+
+func copyBlob[ID comparable](ctx context.Context, src, dst repository.BlobRepository[ID], id ID) error {
+	r, err := src.Read(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	// cancelling the context is the contract to discard a write
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w, err := dst.Write(wctx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		cancel()
+		_ = w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// exists returns nil, if the blob exists, preferably without reading it.
+func exists[ID comparable](ctx context.Context, repo repository.BlobRepository[ID], id ID) error {
+	if sizer, ok := repo.(interface {
+		Size(ctx context.Context, id ID) (int64, error)
+	}); ok {
+		_, err := sizer.Size(ctx, id)
+		return err
+	}
+
+	r, err := repo.Read(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return r.Close()
+}
+
+func checksum[ID comparable](ctx context.Context, repo repository.BlobRepository[ID], id ID) ([]byte, error) {
+	r, err := repo.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// notFound detects the not found errors of blob repositories, which are either file system errors or
+// implement the NotFound contract of EntityNotFoundError.
+func notFound(err error) bool {
+	var nf interface{ NotFound() bool }
+	if errors.As(err, &nf) {
+		return nf.NotFound()
+	}
+
+	return errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,108 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"github.com/golangee/repository"
+)
+
+// Copy writes all entities from src into dst, overwriting any existing entity.
+func Copy[T any, ID comparable](ctx context.Context, src, dst repository.CrudRepository[T, ID], opts Options) (Report[ID], error) {
+	return syncEntities(ctx, src, dst, opts, false)
+}
+
+// Sync writes only the entities from src into dst, which are missing or different. Entities implementing
+// Versioned are compared by version, otherwise by the checksum of their json representation.
+func Sync[T any, ID comparable](ctx context.Context, src, dst repository.CrudRepository[T, ID], opts Options) (Report[ID], error) {
+	return syncEntities(ctx, src, dst, opts, true)
+}
+
+func syncEntities[T any, ID comparable](ctx context.Context, src, dst repository.CrudRepository[T, ID], opts Options, incremental bool) (Report[ID], error) {
+	return run(ctx, opts, func(ctx context.Context, emit func(task[ID]) error) error {
+		seen := map[ID]struct{}{}
+		if err := src.FindAll(func(id ID, entity T) error {
+			if opts.Prune {
+				seen[id] = struct{}{}
+			}
+
+			return emit(func(ctx context.Context) (ID, kind, error) {
+				return syncEntity(dst, id, entity, incremental, opts.DryRun)
+			})
+		}); err != nil {
+			return err
+		}
+
+		if !opts.Prune {
+			return nil
+		}
+
+		var ids []ID
+		if err := dst.FindAll(func(id ID, entity T) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			ids = append(ids, id)
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		return prune(emit, seen, ids, opts.DryRun, dst.DeleteByID)
+	})
+}
+
+func syncEntity[T any, ID comparable](dst repository.CrudRepository[T, ID], id ID, entity T, incremental, dryRun bool) (ID, kind, error) {
+	k := created
+	existing, err := dst.FindByID(id)
+	switch {
+	case err == nil:
+		k = updated
+		if incremental {
+			same, err := equal(entity, existing)
+			if err != nil {
+				return id, k, err
+			}
+
+			if same {
+				return id, unchanged, nil
+			}
+		}
+	case !errors.As(err, &repository.EntityNotFoundError{}):
+		return id, k, err
+	}
+
+	if !dryRun {
+		if err := dst.Save(id, entity); err != nil {
+			return id, k, err
+		}
+	}
+
+	return id, k, nil
+}
+
+func equal[T any](a, b T) (bool, error) {
+	if va, ok := any(a).(Versioned); ok {
+		if vb, ok := any(b).(Versioned); ok {
+			return va.Version() == vb.Version(), nil
+		}
+	}
+
+	bufA, err := json.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+
+	bufB, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+
+	sumA := sha256.Sum256(bufA)
+	sumB := sha256.Sum256(bufB)
+
+	return bytes.Equal(sumA[:], sumB[:]), nil
+}
@@ -0,0 +1,174 @@
+// Package migrate copies and synchronizes the content of any two repositories, e.g. to move data from a
+// prototyping backend like mem or fs into real storage.
+package migrate
+
+import (
+	"context"
+	"sync"
+)
+
+// Options configure a copy or sync run. The zero value is a valid configuration.
+type Options struct {
+	Parallelism int            // Parallelism is the maximum amount of concurrent writes. Values <= 0 default to 4.
+	DryRun      bool           // DryRun only calculates the report without modifying the destination.
+	Prune       bool           // Prune deletes all entries from the destination, which do not exist in the source.
+	Progress    func(Progress) // Progress is invoked serially after each processed entry, if not nil.
+}
+
+// Progress contains the accumulated counters of a running copy or sync.
+type Progress struct {
+	Processed int64
+	Created   int64
+	Updated   int64
+	Unchanged int64
+	Deleted   int64
+}
+
+// Report describes the differences between source and destination. In a dry-run, these are the changes
+// which would have been applied. The order of the IDs is unspecified.
+type Report[ID comparable] struct {
+	Created   []ID  // Created contains the IDs which did not exist in the destination.
+	Updated   []ID  // Updated contains the IDs which existed in the destination and have been overwritten.
+	Unchanged int64 // Unchanged is the amount of identical entries, which have been skipped.
+	Deleted   []ID  // Deleted contains the pruned IDs, which only existed in the destination.
+}
+
+// Versioned can be implemented by entities, to detect changes by comparing versions instead of checksums.
+type Versioned interface {
+	Version() int64
+}
+
+type kind int
+
+const (
+	created kind = iota
+	updated
+	unchanged
+	deleted
+)
+
+// task processes a single entry and returns what has been done.
+type task[ID comparable] func(ctx context.Context) (ID, kind, error)
+
+// run executes the tasks emitted by produce with bounded parallelism and collects the report.
+// The first error cancels all other tasks.
+func run[ID comparable](ctx context.Context, opts Options, produce func(ctx context.Context, emit func(task[ID]) error) error) (Report[ID], error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := opts.Parallelism
+	if workers <= 0 {
+		workers = 4
+	}
+
+	var (
+		report   Report[ID]
+		progress Progress
+		firstErr error
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+	)
+
+	fail := func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	record := func(id ID, k kind) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		progress.Processed++
+		switch k {
+		case created:
+			progress.Created++
+			report.Created = append(report.Created, id)
+		case updated:
+			progress.Updated++
+			report.Updated = append(report.Updated, id)
+		case unchanged:
+			progress.Unchanged++
+			report.Unchanged++
+		case deleted:
+			progress.Deleted++
+			report.Deleted = append(report.Deleted, id)
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
+	tasks := make(chan task[ID])
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for t := range tasks {
+				if ctx.Err() != nil {
+					continue // drain
+				}
+
+				id, k, err := t(ctx)
+				if err != nil {
+					fail(err)
+					continue
+				}
+
+				record(id, k)
+			}
+		}()
+	}
+
+	err := produce(ctx, func(t task[ID]) error {
+		select {
+		case tasks <- t:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	close(tasks)
+	wg.Wait()
+
+	if firstErr != nil {
+		return report, firstErr
+	}
+
+	if err != nil {
+		return report, err
+	}
+
+	return report, ctx.Err()
+}
+
+// prune emits a delete task for each ID of the destination, which is not contained in the source.
+func prune[ID comparable](emit func(task[ID]) error, src map[ID]struct{}, dst []ID, dryRun bool, del func(id ID) error) error {
+	for _, id := range dst {
+		if _, ok := src[id]; ok {
+			continue
+		}
+
+		id := id
+		if err := emit(func(ctx context.Context) (ID, kind, error) {
+			if !dryRun {
+				if err := del(id); err != nil {
+					return id, deleted, err
+				}
+			}
+
+			return id, deleted, nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
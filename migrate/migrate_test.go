@@ -0,0 +1,200 @@
+package migrate
+
+import (
+	"context"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/fs"
+	"github.com/golangee/repository/mem"
+	"io"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	src := mem.NewRepository[string, int]()
+	dst := mem.NewRepository[string, int]()
+
+	for i := 0; i < 10; i++ {
+		must(t, src.Save(i, "v1"))
+	}
+
+	report, err := Copy[string, int](ctx, src, dst, Options{Parallelism: 3})
+	must(t, err)
+	if len(report.Created) != 10 {
+		t.Fatalf("expected 10 created but got %+v", report)
+	}
+
+	must(t, src.Save(3, "v2"))
+	must(t, src.Save(10, "new"))
+	must(t, dst.Save(99, "orphan"))
+
+	// dry-run does not modify anything
+	var last Progress
+	report, err = Sync[string, int](ctx, src, dst, Options{DryRun: true, Prune: true, Progress: func(p Progress) {
+		last = p
+	}})
+	must(t, err)
+
+	expectIDs(t, report.Created, 10)
+	expectIDs(t, report.Updated, 3)
+	expectIDs(t, report.Deleted, 99)
+	if report.Unchanged != 9 || last.Processed != 12 {
+		t.Fatalf("unexpected report %+v and progress %+v", report, last)
+	}
+
+	if v, _ := dst.FindByID(3); v != "v1" {
+		t.Fatalf("dry-run modified destination")
+	}
+
+	// apply
+	report, err = Sync[string, int](ctx, src, dst, Options{Prune: true})
+	must(t, err)
+
+	if n, _ := dst.Count(); n != 11 {
+		t.Fatalf("expected 11 but got %v", n)
+	}
+
+	if v, _ := dst.FindByID(3); v != "v2" {
+		t.Fatalf("expected v2 but got %v", v)
+	}
+
+	// cancellation
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Copy[string, int](cancelled, src, dst, Options{}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestSyncBlobs(t *testing.T) {
+	ctx := context.Background()
+	src, err := fs.NewBlobRepository[string](fs.Dir(t.TempDir()))
+	must(t, err)
+	dst, err := fs.NewBlobRepository[string](fs.Dir(t.TempDir()))
+	must(t, err)
+
+	write := func(repo *fs.BlobRepository[string], id, data string) {
+		w, err := repo.Write(ctx, id)
+		must(t, err)
+		_, err = io.Copy(w, strings.NewReader(data))
+		must(t, err)
+		must(t, w.Close())
+	}
+
+	write(src, "a", "hello")
+	write(src, "b", "world")
+	write(dst, "a", "hello")
+	write(dst, "b", "old")
+
+	report, err := SyncBlobs[string](ctx, src, dst, Options{})
+	must(t, err)
+
+	expectIDs(t, report.Updated, "b")
+	if report.Unchanged != 1 || len(report.Created) != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	r, err := dst.Read(ctx, "b")
+	must(t, err)
+	buf, err := io.ReadAll(r)
+	must(t, err)
+	must(t, r.Close())
+
+	if string(buf) != "world" {
+		t.Fatalf("expected world but got %s", buf)
+	}
+}
+
+func TestCopyBlobs(t *testing.T) {
+	ctx := context.Background()
+	src, err := fs.NewBlobRepository[string](fs.Dir(t.TempDir()))
+	must(t, err)
+	backend, err := fs.NewBlobRepository[string](fs.Dir(t.TempDir()))
+	must(t, err)
+
+	write := func(repo *fs.BlobRepository[string], id, data string) {
+		w, err := repo.Write(ctx, id)
+		must(t, err)
+		_, err = io.Copy(w, strings.NewReader(data))
+		must(t, err)
+		must(t, w.Close())
+	}
+
+	write(src, "a", "hello")
+	write(src, "b", "world")
+	write(backend, "a", "old")
+
+	// hide the Size method, so that the existence is checked by reading
+	dst := &countingReads{BlobRepository: backend}
+	report, err := CopyBlobs[string](ctx, src, dst, Options{})
+	must(t, err)
+
+	expectIDs(t, report.Updated, "a")
+	expectIDs(t, report.Created, "b")
+	if n := atomic.LoadInt64(&dst.n); n != 0 {
+		t.Fatalf("expected no bytes read from the destination but got %v", n)
+	}
+
+	r, err := backend.Read(ctx, "a")
+	must(t, err)
+	buf, err := io.ReadAll(r)
+	must(t, err)
+	must(t, r.Close())
+
+	if string(buf) != "hello" {
+		t.Fatalf("expected hello but got %s", buf)
+	}
+}
+
+// countingReads counts the bytes read from the blobs.
+type countingReads struct {
+	repository.BlobRepository[string]
+	n int64
+}
+
+func (c *countingReads) Read(ctx context.Context, id string) (io.ReadCloser, error) {
+	r, err := c.BlobRepository.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return countingReader{ReadCloser: r, n: &c.n}, nil
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.n, int64(n))
+
+	return n, err
+}
+
+func expectIDs[ID int | string](t *testing.T, actual []ID, expected ...ID) {
+	t.Helper()
+
+	sort.Slice(actual, func(i, j int) bool { return actual[i] < actual[j] })
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, actual)
+	}
+
+	for i := range actual {
+		if actual[i] != expected[i] {
+			t.Fatalf("expected %v but got %v", expected, actual)
+		}
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
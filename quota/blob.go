@@ -0,0 +1,368 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/iter"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// BlobRepository enforces per-tenant quotas and rate limits on the backend. The tenant is taken from the context
+// of each call. Written bytes are accounted while streaming, so that a write which exceeds the quota is discarded
+// before it is committed. Concurrent writes of a tenant reserve their bytes, so they cannot exceed the quota
+// together. Blobs are owned by the tenant which has written them last.
+//
+// The usage is only known for blobs written through this instance. To recover the usage after a restart,
+// call Scan before serving any requests.
+type BlobRepository[ID comparable] struct {
+	backend repository.BlobRepository[ID]
+	opts    options
+
+	mutex   sync.Mutex
+	usage   map[string]*usage
+	blobs   map[ID]blobInfo
+	buckets map[bucketKey]*bucket
+}
+
+type usage struct {
+	Usage
+	pendingBlobs int64
+	pendingBytes int64
+}
+
+type blobInfo struct {
+	tenant string
+	size   int64
+}
+
+type bucketKey struct {
+	tenant string
+	op     Op
+}
+
+// NewBlobRepository creates a quota enforcing decorator for the given backend.
+func NewBlobRepository[ID comparable](backend repository.BlobRepository[ID], opts ...Option) *BlobRepository[ID] {
+	return &BlobRepository[ID]{
+		backend: backend,
+		opts:    newOptions(opts),
+		usage:   map[string]*usage{},
+		blobs:   map[ID]blobInfo{},
+		buckets: map[bucketKey]*bucket{},
+	}
+}
+
+// Scan replaces the accounted usage by scanning all blobs of the backend. The owner function returns the tenant
+// of a blob, e.g. derived from a tenant prefix of the ID. If the backend provides a Size method, like
+// fs.BlobRepository, blobs are not read entirely. Scan must not be called concurrently with writes.
+func (r *BlobRepository[ID]) Scan(ctx context.Context, owner func(id ID) string) error {
+	it, err := r.backend.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	usages := map[string]*usage{}
+	blobs := map[ID]blobInfo{}
+	err = iter.Walk(it, func(id ID) error {
+		size, err := r.size(ctx, id)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil // deleted concurrently
+			}
+
+			return err
+		}
+
+		tenant := owner(id)
+		u := usages[tenant]
+		if u == nil {
+			u = &usage{}
+			usages[tenant] = u
+		}
+
+		u.Blobs++
+		u.Bytes += size
+		blobs[id] = blobInfo{tenant: tenant, size: size}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.usage = usages
+	r.blobs = blobs
+
+	return nil
+}
+
+// size returns the size of the blob, preferably without reading it.
+func (r *BlobRepository[ID]) size(ctx context.Context, id ID) (int64, error) {
+	if sizer, ok := r.backend.(interface {
+		Size(ctx context.Context, id ID) (int64, error)
+	}); ok {
+		return sizer.Size(ctx, id)
+	}
+
+	reader, err := r.backend.Read(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	defer reader.Close()
+
+	return io.Copy(io.Discard, reader)
+}
+
+// Usage returns the accounted usage of the tenant.
+func (r *BlobRepository[ID]) Usage(tenant string) Usage {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if u := r.usage[tenant]; u != nil {
+		return u.Usage
+	}
+
+	return Usage{}
+}
+
+func (r *BlobRepository[ID]) Count(ctx context.Context) (int64, error) {
+	if err := r.limit(ctx, OpCount); err != nil {
+		return 0, err
+	}
+
+	return r.backend.Count(ctx)
+}
+
+func (r *BlobRepository[ID]) Delete(ctx context.Context, id ID) error {
+	if err := r.limit(ctx, OpDelete); err != nil {
+		return err
+	}
+
+	if err := r.backend.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.release(id)
+
+	return nil
+}
+
+// DeleteAll clears the backend and resets the usage of all tenants.
+func (r *BlobRepository[ID]) DeleteAll(ctx context.Context) error {
+	if err := r.limit(ctx, OpDeleteAll); err != nil {
+		return err
+	}
+
+	if err := r.backend.DeleteAll(ctx); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id := range r.blobs {
+		r.release(id)
+	}
+
+	return nil
+}
+
+// Write fails with a QuotaExceededError, if the tenant cannot create another blob. Each write of the returned
+// writer fails with a QuotaExceededError, if the tenant would exceed its byte quota. In that case, the blob
+// is discarded when closing.
+func (r *BlobRepository[ID]) Write(ctx context.Context, id ID) (io.WriteCloser, error) {
+	if err := r.limit(ctx, OpWrite); err != nil {
+		return nil, err
+	}
+
+	tenant := Tenant(ctx)
+
+	r.mutex.Lock()
+	u := r.usageOf(tenant)
+	info, exists := r.blobs[id]
+	created := !exists || info.tenant != tenant
+	if max := r.opts.limitsOf(tenant).MaxBlobs; created && max > 0 && u.Blobs+u.pendingBlobs >= max {
+		r.mutex.Unlock()
+		return nil, QuotaExceededError{Tenant: tenant, Limit: "blobs"}
+	}
+
+	if created {
+		u.pendingBlobs++
+	}
+	r.mutex.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	w, err := r.backend.Write(ctx, id)
+	if err != nil {
+		cancel()
+		r.mutex.Lock()
+		if created {
+			u.pendingBlobs--
+		}
+		r.mutex.Unlock()
+
+		return nil, err
+	}
+
+	return &writer[ID]{repo: r, tenant: tenant, id: id, usage: u, created: created, w: w, cancel: cancel}, nil
+}
+
+func (r *BlobRepository[ID]) Read(ctx context.Context, id ID) (io.ReadCloser, error) {
+	if err := r.limit(ctx, OpRead); err != nil {
+		return nil, err
+	}
+
+	return r.backend.Read(ctx, id)
+}
+
+func (r *BlobRepository[ID]) FindAll(ctx context.Context) (iter.Iterator[ID], error) {
+	if err := r.limit(ctx, OpFindAll); err != nil {
+		return nil, err
+	}
+
+	return r.backend.FindAll(ctx)
+}
+
+// limit waits for a token of the tenant's bucket of the operation, if rate limited.
+func (r *BlobRepository[ID]) limit(ctx context.Context, op Op) error {
+	rt, ok := r.opts.rates[op]
+	if !ok {
+		return nil
+	}
+
+	key := bucketKey{tenant: Tenant(ctx), op: op}
+
+	r.mutex.Lock()
+	b := r.buckets[key]
+	if b == nil {
+		b = newBucket(rt, r.opts.now())
+		r.buckets[key] = b
+	}
+
+	d := b.reserve(r.opts.now())
+	r.mutex.Unlock()
+
+	if err := wait(ctx, d); err != nil {
+		r.mutex.Lock()
+		b.cancel()
+		r.mutex.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// usageOf returns the usage of the tenant. The caller must hold the lock.
+func (r *BlobRepository[ID]) usageOf(tenant string) *usage {
+	u := r.usage[tenant]
+	if u == nil {
+		u = &usage{}
+		r.usage[tenant] = u
+	}
+
+	return u
+}
+
+// release removes the blob from the usage of its owner. The caller must hold the lock.
+func (r *BlobRepository[ID]) release(id ID) {
+	if info, ok := r.blobs[id]; ok {
+		u := r.usageOf(info.tenant)
+		u.Blobs--
+		u.Bytes -= info.size
+		delete(r.blobs, id)
+	}
+}
+
+// writer reserves the bytes of the tenant while streaming and accounts them when committed.
+type writer[ID comparable] struct {
+	repo    *BlobRepository[ID]
+	tenant  string
+	id      ID
+	usage   *usage
+	created bool // created is true, if the blob accounts as a new blob of the tenant
+	w       io.WriteCloser
+	cancel  context.CancelFunc
+	n       int64
+	err     error // err is the quota violation, which discards the blob
+	closed  bool
+}
+
+func (w *writer[ID]) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	r := w.repo
+	r.mutex.Lock()
+	if max := r.opts.limitsOf(w.tenant).MaxBytes; max > 0 {
+		replaced := int64(0)
+		if info, ok := r.blobs[w.id]; ok && info.tenant == w.tenant {
+			replaced = info.size
+		}
+
+		if w.usage.Bytes+w.usage.pendingBytes-replaced+int64(len(p)) > max {
+			r.mutex.Unlock()
+			w.err = QuotaExceededError{Tenant: w.tenant, Limit: "bytes"}
+			w.cancel() // discard the blob
+
+			return 0, w.err
+		}
+	}
+
+	w.usage.pendingBytes += int64(len(p))
+	r.mutex.Unlock()
+
+	n, err := w.w.Write(p)
+
+	r.mutex.Lock()
+	w.usage.pendingBytes -= int64(len(p) - n)
+	r.mutex.Unlock()
+	w.n += int64(n)
+
+	return n, err
+}
+
+func (w *writer[ID]) Close() error {
+	if w.closed {
+		return w.err
+	}
+
+	w.closed = true
+	defer w.cancel()
+
+	err := w.w.Close()
+	if w.err != nil {
+		err = w.err
+	}
+
+	r := w.repo
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	w.usage.pendingBytes -= w.n
+	if w.created {
+		w.usage.pendingBlobs--
+	}
+
+	if err != nil {
+		return err
+	}
+
+	r.release(w.id)
+	w.usage.Blobs++
+	w.usage.Bytes += w.n
+	r.blobs[w.id] = blobInfo{tenant: w.tenant, size: w.n}
+
+	return nil
+}
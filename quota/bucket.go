@@ -0,0 +1,66 @@
+package quota
+
+import (
+	"context"
+	"time"
+)
+
+// bucket is a token bucket. It is not thread safe.
+type bucket struct {
+	rate   rate
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(r rate, now time.Time) *bucket {
+	return &bucket{rate: r, tokens: float64(r.burst), last: now}
+}
+
+// reserve takes a token and returns how long to wait until the token becomes available.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate.perSecond
+		if max := float64(b.rate.burst); b.tokens > max {
+			b.tokens = max
+		}
+
+		b.last = now
+	}
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	if b.rate.perSecond <= 0 {
+		return -1 // never refills
+	}
+
+	return time.Duration(-b.tokens / b.rate.perSecond * float64(time.Second))
+}
+
+// cancel returns a reserved token, e.g. because the waiting caller gave up.
+func (b *bucket) cancel() {
+	b.tokens++
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	if d == 0 {
+		return nil
+	}
+
+	if d < 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package quota
+
+import (
+	"time"
+)
+
+// Op denotes a rate limited operation.
+type Op string
+
+const (
+	OpCount     Op = "Count"
+	OpDelete    Op = "Delete"
+	OpDeleteAll Op = "DeleteAll"
+	OpWrite     Op = "Write"
+	OpRead      Op = "Read"
+	OpFindAll   Op = "FindAll"
+)
+
+// Option configures a BlobRepository.
+type Option func(*options)
+
+type options struct {
+	limits map[string]Limits
+	def    Limits
+	rates  map[Op]rate
+	owner  func(id any) string
+	now    func() time.Time
+}
+
+type rate struct {
+	perSecond float64
+	burst     int
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		limits: map[string]Limits{},
+		rates:  map[Op]rate{},
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+func (o *options) limitsOf(tenant string) Limits {
+	if l, ok := o.limits[tenant]; ok {
+		return l
+	}
+
+	return o.def
+}
+
+// WithDefaultLimits sets the limits of all tenants without individual limits. Defaults to unlimited.
+func WithDefaultLimits(l Limits) Option {
+	return func(o *options) {
+		o.def = l
+	}
+}
+
+// WithLimits sets the limits of the given tenant.
+func WithLimits(tenant string, l Limits) Option {
+	return func(o *options) {
+		o.limits[tenant] = l
+	}
+}
+
+// WithRateLimit limits the operation per tenant using a token bucket, which refills with the given rate per second
+// and holds at most burst tokens. Calls wait for a token until their context is done.
+func WithRateLimit(op Op, perSecond float64, burst int) Option {
+	return func(o *options) {
+		if burst < 1 {
+			burst = 1
+		}
+
+		o.rates[op] = rate{perSecond: perSecond, burst: burst}
+	}
+}
+
+// WithClock replaces the clock, which is used to refill the token buckets.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
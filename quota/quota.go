@@ -0,0 +1,42 @@
+// Package quota provides a decorator, which enforces per-tenant quotas and rate limits on a BlobRepository.
+package quota
+
+import (
+	"context"
+	"fmt"
+)
+
+type tenantKey struct{}
+
+// WithTenant returns a context which identifies the tenant of all calls performed using it.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the tenant of the context or the empty string, which denotes the default tenant.
+func Tenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// Limits define the quota of a tenant. Values <= 0 mean unlimited.
+type Limits struct {
+	MaxBlobs int64
+	MaxBytes int64
+}
+
+// Usage is the accounted usage of a tenant, excluding writes which have not been committed yet.
+type Usage struct {
+	Blobs int64
+	Bytes int64
+}
+
+// QuotaExceededError is returned, if a write would exceed the quota of its tenant. The write is discarded.
+type QuotaExceededError struct {
+	Tenant string
+	Limit  string // Limit is either "blobs" or "bytes".
+}
+
+func (e QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota of tenant '%s' exceeded: %s", e.Tenant, e.Limit)
+}
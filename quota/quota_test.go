@@ -0,0 +1,122 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"github.com/golangee/repository/fs"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func write(ctx context.Context, r *BlobRepository[string], id, data string) error {
+	w, err := r.Write(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, data); err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+func TestBlobRepository(t *testing.T) {
+	blobs, err := fs.NewBlobRepository[string](fs.Dir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewBlobRepository[string](blobs, WithDefaultLimits(Limits{MaxBlobs: 2, MaxBytes: 10}))
+	alice := WithTenant(context.Background(), "alice")
+	bob := WithTenant(context.Background(), "bob")
+
+	if err := write(alice, repo, "alice/a", "12345"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := write(alice, repo, "alice/b", "1234567"); !errors.As(err, &QuotaExceededError{}) {
+		t.Fatalf("expected byte quota violation but got %v", err)
+	}
+
+	if _, err := blobs.Size(alice, "alice/b"); err == nil {
+		t.Fatal("over quota blob must not be committed")
+	}
+
+	// overwriting releases the previous size
+	if err := write(alice, repo, "alice/a", "1234567890"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := write(bob, repo, "bob/a", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := write(bob, repo, "bob/b", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Write(bob, "bob/c"); !errors.As(err, &QuotaExceededError{}) {
+		t.Fatalf("expected blob quota violation but got %v", err)
+	}
+
+	if u := repo.Usage("alice"); u != (Usage{Blobs: 1, Bytes: 10}) {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+
+	if err := repo.Delete(bob, "bob/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// recover after restart
+	restarted := NewBlobRepository[string](blobs)
+	if err := restarted.Scan(context.Background(), func(id string) string {
+		return strings.Split(id, "/")[0]
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if u := restarted.Usage("alice"); u != (Usage{Blobs: 1, Bytes: 10}) {
+		t.Fatalf("unexpected recovered usage %+v", u)
+	}
+
+	if u := restarted.Usage("bob"); u != (Usage{Blobs: 1, Bytes: 1}) {
+		t.Fatalf("unexpected recovered usage %+v", u)
+	}
+}
+
+func TestBlobRepository_RateLimit(t *testing.T) {
+	blobs, err := fs.NewBlobRepository[string](fs.Dir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(0, 0)
+	repo := NewBlobRepository[string](blobs, WithRateLimit(OpCount, 1, 2), WithClock(func() time.Time { return now }))
+
+	for i := 0; i < 2; i++ {
+		if _, err := repo.Count(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := repo.Count(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected rate limit but got %v", err)
+	}
+
+	// other tenants have their own bucket
+	if _, err := repo.Count(WithTenant(context.Background(), "other")); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Second)
+	if _, err := repo.Count(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,156 @@
+package resilience
+
+import (
+	"context"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/iter"
+	"io"
+)
+
+// BlobRepository retries transient failures of the backend. Opening a stream is retried, however a stream
+// itself is only retried by Write as long as no byte has been accepted by the backend.
+type BlobRepository[ID comparable] struct {
+	backend repository.BlobRepository[ID]
+	opts    options
+}
+
+// NewBlobRepository creates a retrying decorator for the given backend.
+func NewBlobRepository[ID comparable](backend repository.BlobRepository[ID], opts ...Option) *BlobRepository[ID] {
+	return &BlobRepository[ID]{
+		backend: backend,
+		opts:    newOptions(opts),
+	}
+}
+
+func (r *BlobRepository[ID]) Count(ctx context.Context) (int64, error) {
+	var n int64
+	err := r.opts.do(ctx, func() (err error) {
+		n, err = r.backend.Count(ctx)
+		return err
+	})
+
+	return n, err
+}
+
+func (r *BlobRepository[ID]) Delete(ctx context.Context, id ID) error {
+	return r.opts.do(ctx, func() error {
+		return r.backend.Delete(ctx, id)
+	})
+}
+
+func (r *BlobRepository[ID]) DeleteAll(ctx context.Context) error {
+	return r.opts.do(ctx, func() error {
+		return r.backend.DeleteAll(ctx)
+	})
+}
+
+// Write opens the blob and retries to reopen it, if the first write fails before any byte has been accepted.
+// Afterwards, failures are returned as is and the caller must start over.
+func (r *BlobRepository[ID]) Write(ctx context.Context, id ID) (io.WriteCloser, error) {
+	w := &writer[ID]{ctx: ctx, repo: r, id: id}
+	if err := r.opts.do(ctx, w.open); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (r *BlobRepository[ID]) Read(ctx context.Context, id ID) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	err := r.opts.do(ctx, func() (err error) {
+		rc, err = r.backend.Read(ctx, id)
+		return err
+	})
+
+	return rc, err
+}
+
+func (r *BlobRepository[ID]) FindAll(ctx context.Context) (iter.Iterator[ID], error) {
+	var it iter.Iterator[ID]
+	err := r.opts.do(ctx, func() (err error) {
+		it, err = r.backend.FindAll(ctx)
+		return err
+	})
+
+	return it, err
+}
+
+// writer reopens the backend writer until the first byte has been written. Each backend writer gets its own
+// context, so that a failed attempt can be discarded by cancelling it.
+type writer[ID comparable] struct {
+	ctx     context.Context
+	repo    *BlobRepository[ID]
+	id      ID
+	w       io.WriteCloser
+	cancel  context.CancelFunc
+	written bool
+	err     error // err is the last error, if the writer could not be reopened
+}
+
+func (w *writer[ID]) open() error {
+	ctx, cancel := context.WithCancel(w.ctx)
+	bw, err := w.repo.backend.Write(ctx, w.id)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	w.w, w.cancel = bw, cancel
+
+	return nil
+}
+
+// discard cancels and closes the current backend writer, so that nothing is committed.
+func (w *writer[ID]) discard() {
+	w.cancel()
+	_ = w.w.Close()
+}
+
+func (w *writer[ID]) Write(p []byte) (int, error) {
+	if w.w == nil {
+		return 0, w.err
+	}
+
+	if w.written || len(p) == 0 {
+		n, err := w.w.Write(p)
+		w.written = w.written || n > 0
+
+		return n, err
+	}
+
+	var n int
+	err := w.repo.opts.do(w.ctx, func() (err error) {
+		if w.w == nil {
+			if err := w.open(); err != nil {
+				return err
+			}
+		}
+
+		n, err = w.w.Write(p)
+		if err != nil && n == 0 {
+			w.discard()
+			w.w = nil
+		}
+
+		return err
+	})
+
+	if w.w == nil {
+		w.err = err // all attempts failed, the writer is unusable
+		return 0, err
+	}
+
+	w.written = n > 0
+
+	return n, err
+}
+
+func (w *writer[ID]) Close() error {
+	if w.w == nil {
+		return w.err
+	}
+
+	defer w.cancel()
+
+	return w.w.Close()
+}
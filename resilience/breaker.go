@@ -0,0 +1,121 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// CircuitOpen is returned without calling the backend, while the circuit breaker is open.
+var CircuitOpen = errors.New("circuit breaker is open")
+
+// State of a Breaker.
+type State int
+
+const (
+	Closed   State = iota // Closed lets all calls pass.
+	Open                  // Open rejects all calls until the cooldown has passed.
+	HalfOpen              // HalfOpen lets a single trial call pass, which decides whether to close or open again.
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker is a circuit breaker, which opens after a threshold of consecutive transient failures and rejects
+// all calls until the cooldown has passed. Errors which are not transient, like a not found error, prove
+// that the backend is healthy and count as success.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mutex    sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool // trial is true while the trial call of the half-open state is in flight
+}
+
+// NewBreaker creates a closed circuit breaker. A threshold <= 0 defaults to 5 consecutive failures.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+
+	return &Breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// State returns the current state.
+func (b *Breaker) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == Open && !b.now().Before(b.openedAt.Add(b.cooldown)) {
+		return HalfOpen
+	}
+
+	return b.state
+}
+
+// allow returns CircuitOpen, if the call must be rejected. A nil Breaker allows all calls.
+func (b *Breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.now().Before(b.openedAt.Add(b.cooldown)) {
+			return CircuitOpen
+		}
+
+		b.state = HalfOpen
+		b.trial = true
+		return nil
+	case HalfOpen:
+		if b.trial {
+			return CircuitOpen
+		}
+
+		b.trial = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// report records the result of an allowed call.
+func (b *Breaker) report(failure bool) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.trial = false
+	if !failure {
+		b.state = Closed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		b.state = Open
+		b.openedAt = b.now()
+	}
+}
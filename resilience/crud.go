@@ -0,0 +1,108 @@
+package resilience
+
+import (
+	"context"
+	"github.com/golangee/repository"
+	"io"
+)
+
+// Repository retries transient failures of the backend. All methods are idempotent and retried, except that
+// FindAll is only retried as long as the consumer has not been invoked. Because CrudRepository methods do not
+// accept a context, the backoff is interrupted by the context bound by WithContext.
+type Repository[T any, ID comparable] struct {
+	backend repository.CrudRepository[T, ID]
+	opts    options
+	ctx     context.Context
+}
+
+// NewRepository creates a retrying decorator for the given backend.
+func NewRepository[T any, ID comparable](backend repository.CrudRepository[T, ID], opts ...Option) *Repository[T, ID] {
+	return &Repository[T, ID]{
+		backend: backend,
+		opts:    newOptions(opts),
+		ctx:     context.Background(),
+	}
+}
+
+// WithContext returns a shallow copy which stops retrying when the given context is done.
+func (r *Repository[T, ID]) WithContext(ctx context.Context) *Repository[T, ID] {
+	c := *r
+	c.ctx = ctx
+
+	return &c
+}
+
+func (r *Repository[T, ID]) Count() (int64, error) {
+	var n int64
+	err := r.opts.do(r.ctx, func() (err error) {
+		n, err = r.backend.Count()
+		return err
+	})
+
+	return n, err
+}
+
+func (r *Repository[T, ID]) DeleteByID(id ID) error {
+	return r.opts.do(r.ctx, func() error {
+		return r.backend.DeleteByID(id)
+	})
+}
+
+func (r *Repository[T, ID]) DeleteAll() error {
+	return r.opts.do(r.ctx, func() error {
+		return r.backend.DeleteAll()
+	})
+}
+
+func (r *Repository[T, ID]) Save(id ID, entity T) error {
+	return r.opts.do(r.ctx, func() error {
+		return r.backend.Save(id, entity)
+	})
+}
+
+// SaveAll saves and retries each entity individually, because a producer cannot be rewound.
+func (r *Repository[T, ID]) SaveAll(f func() (ID, T, error)) error {
+	for {
+		id, entity, err := f()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := r.Save(id, entity); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Repository[T, ID]) FindByID(id ID) (T, error) {
+	var entity T
+	err := r.opts.do(r.ctx, func() (err error) {
+		entity, err = r.backend.FindByID(id)
+		return err
+	})
+
+	return entity, err
+}
+
+// FindAll is retried only until the first entity has been passed to the consumer, to avoid duplicates.
+func (r *Repository[T, ID]) FindAll(f func(id ID, entity T) error) error {
+	consumed := false
+	err := r.opts.do(r.ctx, func() error {
+		err := r.backend.FindAll(func(id ID, entity T) error {
+			consumed = true
+			return f(id, entity)
+		})
+
+		if consumed && err != nil {
+			return permanent{err} // also never retry an error of the consumer
+		}
+
+		return err
+	})
+
+	return unwrapPermanent(err)
+}
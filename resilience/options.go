@@ -0,0 +1,87 @@
+package resilience
+
+import (
+	"context"
+	"time"
+)
+
+// Option configures a decorator.
+type Option func(*options)
+
+type options struct {
+	attempts  int
+	base      time.Duration
+	max       time.Duration
+	retryable func(err error) bool
+	breaker   *Breaker
+	sleep     func(ctx context.Context, d time.Duration) error
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		attempts:  3,
+		base:      50 * time.Millisecond,
+		max:       5 * time.Second,
+		retryable: Retryable,
+		sleep:     sleep,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithMaxAttempts sets the maximum amount of attempts per call, including the first one. Defaults to 3.
+func WithMaxAttempts(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.attempts = n
+		}
+	}
+}
+
+// WithBackoff sets the base delay, which is doubled after each failed attempt up to max. The actual delay
+// is randomized between zero and the calculated delay (full jitter). Defaults to 50ms and 5s.
+func WithBackoff(base, max time.Duration) Option {
+	return func(o *options) {
+		o.base = base
+		o.max = max
+	}
+}
+
+// WithClassifier replaces Retryable to decide which errors are transient.
+func WithClassifier(retryable func(err error) bool) Option {
+	return func(o *options) {
+		o.retryable = retryable
+	}
+}
+
+// WithBreaker guards all attempts by the given circuit breaker, which may be shared between decorators of the
+// same backend.
+func WithBreaker(b *Breaker) Option {
+	return func(o *options) {
+		o.breaker = b
+	}
+}
+
+// WithSleep replaces the function which waits between attempts, e.g. to avoid real delays in tests.
+// The function must return early with the context error, if ctx is done.
+func WithSleep(sleep func(ctx context.Context, d time.Duration) error) Option {
+	return func(o *options) {
+		o.sleep = sleep
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
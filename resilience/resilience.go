@@ -0,0 +1,111 @@
+// Package resilience provides decorators, which retry transient failures of a CrudRepository or BlobRepository
+// with exponential backoff and protect the backend by a circuit breaker.
+package resilience
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	rfs "github.com/golangee/repository/fs"
+	"github.com/golangee/repository/schema"
+	"io/fs"
+	"math/rand"
+	"time"
+)
+
+// Retryable is the default classifier and returns true for all errors, which may be transient. Not found errors,
+// cancelled contexts, invalid arguments, permission or encoding errors, missing capabilities and changes which have
+// been applied but are not durable are permanent, because retrying would apply the change again.
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var nf interface{ NotFound() bool }
+	if errors.As(err, &nf) && nf.NotFound() {
+		return false
+	}
+
+	var versionErr schema.VersionError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var unsupportedErr *json.UnsupportedTypeError
+	if errors.As(err, &versionErr) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.As(err, &unsupportedErr) {
+		return false
+	}
+
+	for _, permanent := range []error{
+		context.Canceled, context.DeadlineExceeded, CircuitOpen,
+		fs.ErrNotExist, fs.ErrExist, fs.ErrInvalid, fs.ErrPermission, fs.ErrClosed,
+		rfs.InvalidFilename, rfs.MkDirNotSupported, rfs.RemoveNotSupported, rfs.FileOpenNotSupported,
+		rfs.WriteableFileNotSupported, rfs.RenameFileNotSupported, rfs.WriteNotSupported, rfs.ReadOnly,
+		rfs.NotDurable,
+	} {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// do invokes f until it succeeds, fails permanently, the attempts are exhausted or ctx is done.
+func (o *options) do(ctx context.Context, f func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := o.breaker.allow(); err != nil {
+			return err
+		}
+
+		err := f()
+		_, isPermanent := err.(permanent)
+		transient := err != nil && !isPermanent && o.retryable(err)
+		o.breaker.report(transient)
+
+		if !transient || attempt >= o.attempts {
+			return err
+		}
+
+		if o.sleep(ctx, o.backoff(attempt)) != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns the randomized delay after the given failed attempt.
+func (o *options) backoff(attempt int) time.Duration {
+	d := o.base
+	for i := 1; i < attempt && d < o.max; i++ {
+		d *= 2
+	}
+
+	if d > o.max {
+		d = o.max
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// permanent marks an error as not retryable, regardless of the classifier.
+type permanent struct {
+	err error
+}
+
+func (p permanent) Error() string {
+	return p.err.Error()
+}
+
+func (p permanent) Unwrap() error {
+	return p.err
+}
+
+func unwrapPermanent(err error) error {
+	if p, ok := err.(permanent); ok {
+		return p.err
+	}
+
+	return err
+}
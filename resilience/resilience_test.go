@@ -0,0 +1,200 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golangee/repository"
+	"github.com/golangee/repository/fs"
+	"github.com/golangee/repository/internal/test"
+	"github.com/golangee/repository/mem"
+	"io"
+	"testing"
+	"time"
+)
+
+var transient = errors.New("stale NFS file handle")
+
+func noSleep(ctx context.Context, d time.Duration) error {
+	return ctx.Err()
+}
+
+// flaky fails the first n calls of Save and FindByID.
+type flaky[T any, ID comparable] struct {
+	repository.CrudRepository[T, ID]
+	n     int
+	calls int
+}
+
+func (f *flaky[T, ID]) fail() error {
+	f.calls++
+	if f.calls <= f.n {
+		return transient
+	}
+
+	return nil
+}
+
+func (f *flaky[T, ID]) Save(id ID, entity T) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+
+	return f.CrudRepository.Save(id, entity)
+}
+
+func (f *flaky[T, ID]) FindByID(id ID) (T, error) {
+	if err := f.fail(); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return f.CrudRepository.FindByID(id)
+}
+
+func TestRepository(t *testing.T) {
+	test.Test[*test.B, int](t, test.CreateTestSet3(), NewRepository[*test.B, int](mem.NewRepository[*test.B, int]()))
+
+	backend := &flaky[string, int]{CrudRepository: mem.NewRepository[string, int](), n: 2}
+	repo := NewRepository[string, int](backend, WithSleep(noSleep))
+	if err := repo.Save(1, "one"); err != nil {
+		t.Fatal(err)
+	}
+
+	if backend.calls != 3 {
+		t.Fatalf("expected 3 attempts but got %v", backend.calls)
+	}
+
+	backend.calls = 0
+	if _, err := repo.FindByID(2); !errors.As(err, &repository.EntityNotFoundError{}) {
+		t.Fatalf("expected not found but got %v", err)
+	}
+
+	if backend.calls != 3 {
+		t.Fatalf("not found must not be retried, but got %v attempts", backend.calls)
+	}
+
+	backend.calls, backend.n = 0, 10
+	if err := repo.Save(1, "one"); !errors.Is(err, transient) || backend.calls != 3 {
+		t.Fatalf("expected exhausted attempts but got %v after %v attempts", err, backend.calls)
+	}
+
+	// an applied change must not be applied again
+	if Retryable(fmt.Errorf("%w: %v", fs.NotDurable, transient)) {
+		t.Fatal("expected permanent error")
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	backend := &flaky[string, int]{CrudRepository: mem.NewRepository[string, int](), n: 4}
+	repo := NewRepository[string, int](backend, WithSleep(noSleep), WithMaxAttempts(1), WithBreaker(b))
+
+	for i := 0; i < 2; i++ {
+		if err := repo.Save(1, "one"); !errors.Is(err, transient) {
+			t.Fatalf("expected transient error but got %v", err)
+		}
+	}
+
+	if err := repo.Save(1, "one"); !errors.Is(err, CircuitOpen) || b.State() != Open {
+		t.Fatalf("expected open circuit but got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if b.State() != HalfOpen {
+		t.Fatalf("expected half-open but got %v", b.State())
+	}
+
+	// the trial fails and opens again
+	if err := repo.Save(1, "one"); !errors.Is(err, transient) || b.State() != Open {
+		t.Fatalf("expected failed trial but got %v in state %v", err, b.State())
+	}
+
+	now = now.Add(time.Minute)
+	backend.n = 0
+	if err := repo.Save(1, "one"); err != nil || b.State() != Closed {
+		t.Fatalf("expected closed circuit but got %v in state %v", err, b.State())
+	}
+}
+
+// flakyBlobs returns writers, which fail the first write of the first n writers.
+type flakyBlobs struct {
+	repository.BlobRepository[string]
+	n      int
+	writes int
+}
+
+func (f *flakyBlobs) Write(ctx context.Context, id string) (io.WriteCloser, error) {
+	w, err := f.BlobRepository.Write(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	f.writes++
+
+	return &flakyWriter{WriteCloser: w, fail: f.writes <= f.n}, nil
+}
+
+type flakyWriter struct {
+	io.WriteCloser
+	fail bool
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		w.fail = false
+		return 0, transient
+	}
+
+	return w.WriteCloser.Write(p)
+}
+
+func TestBlobRepository(t *testing.T) {
+	ctx := context.Background()
+	blobs, err := fs.NewBlobRepository[string](fs.Dir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &flakyBlobs{BlobRepository: blobs, n: 2}
+	repo := NewBlobRepository[string](backend, WithSleep(noSleep))
+
+	w, err := repo.Write(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(w, "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(w, " world"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if backend.writes != 3 {
+		t.Fatalf("expected 3 opened writers but got %v", backend.writes)
+	}
+
+	r, err := repo.Read(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+
+	if buf, err := io.ReadAll(r); err != nil || string(buf) != "hello world" {
+		t.Fatalf("unexpected blob %q: %v", buf, err)
+	}
+
+	if n, err := repo.Count(ctx); err != nil || n != 1 {
+		t.Fatalf("discarded attempts must not be committed, got %v: %v", n, err)
+	}
+}
@@ -0,0 +1,104 @@
+// Package schema provides the versioning of stored json documents and the upcasting of old documents into the
+// current shape of an entity, e.g. to support renamed or restructured fields.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// An Upcaster transforms a raw json document of a specific version into the next version.
+type Upcaster func(doc json.RawMessage) (json.RawMessage, error)
+
+// VersionError is returned, if a document cannot be upcasted into the current version.
+type VersionError struct {
+	Version int
+	Current int
+}
+
+func (e VersionError) Error() string {
+	return fmt.Sprintf("cannot upcast schema version %d to %d", e.Version, e.Current)
+}
+
+// Registry contains the current schema version and the upcasters for all previous versions.
+// Documents written before versioning was introduced have the version 0. A Registry must not be modified
+// after it has been passed to a repository.
+type Registry struct {
+	current   int
+	upcasters map[int]Upcaster
+}
+
+// NewRegistry creates a registry with the given current version.
+func NewRegistry(current int) *Registry {
+	return &Registry{current: current, upcasters: map[int]Upcaster{}}
+}
+
+// Register sets the upcaster which transforms documents of version from into version from+1.
+func (r *Registry) Register(from int, f Upcaster) *Registry {
+	r.upcasters[from] = f
+	return r
+}
+
+// Current returns the version of newly written documents. A nil registry has the version 0.
+func (r *Registry) Current() int {
+	if r == nil {
+		return 0
+	}
+
+	return r.current
+}
+
+// Upcast applies all upcasters in order to transform the document of the given version into the current version.
+func (r *Registry) Upcast(version int, doc json.RawMessage) (json.RawMessage, error) {
+	current := r.Current()
+	if version > current {
+		return nil, VersionError{Version: version, Current: current}
+	}
+
+	for v := version; v < current; v++ {
+		f := r.upcasters[v]
+		if f == nil {
+			return nil, VersionError{Version: version, Current: current}
+		}
+
+		next, err := f(doc)
+		if err != nil {
+			return nil, fmt.Errorf("cannot upcast schema version %d: %w", v, err)
+		}
+
+		doc = next
+	}
+
+	return doc, nil
+}
+
+type envelope struct {
+	Version int             `json:"_schema"`
+	Data    json.RawMessage `json:"_data"`
+}
+
+// Encode wraps the document into a self-describing envelope carrying the version.
+func Encode(version int, doc json.RawMessage) ([]byte, error) {
+	return json.Marshal(envelope{Version: version, Data: doc})
+}
+
+// Decode unwraps a document created by Encode. Any other document is returned unmodified with version 0.
+func Decode(buf []byte) (int, json.RawMessage) {
+	if !bytes.HasPrefix(bytes.TrimSpace(buf), []byte("{")) {
+		return 0, buf
+	}
+
+	// only an object with exactly both fields is an envelope, to avoid confusion with legacy entities
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &fields); err != nil || len(fields) != 2 || fields["_data"] == nil {
+		return 0, buf
+	}
+
+	var version int
+	if err := json.Unmarshal(fields["_schema"], &version); err != nil {
+		return 0, buf
+	}
+
+	return version, fields["_data"]
+}
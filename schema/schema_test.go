@@ -0,0 +1,62 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestRegistry_Upcast(t *testing.T) {
+	reg := NewRegistry(2).
+		Register(0, func(doc json.RawMessage) (json.RawMessage, error) {
+			var v map[string]any
+			if err := json.Unmarshal(doc, &v); err != nil {
+				return nil, err
+			}
+
+			v["Firstname"] = v["Name"]
+			delete(v, "Name")
+
+			return json.Marshal(v)
+		}).
+		Register(1, func(doc json.RawMessage) (json.RawMessage, error) {
+			var v map[string]any
+			if err := json.Unmarshal(doc, &v); err != nil {
+				return nil, err
+			}
+
+			v["Age"] = 42
+
+			return json.Marshal(v)
+		})
+
+	doc, err := reg.Upcast(0, json.RawMessage(`{"Name":"otto"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(doc) != `{"Age":42,"Firstname":"otto"}` {
+		t.Fatalf("unexpected document %s", doc)
+	}
+
+	if _, err := reg.Upcast(3, doc); !errors.As(err, &VersionError{}) {
+		t.Fatalf("expected version error but got %v", err)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	buf, err := Encode(3, json.RawMessage(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, doc := Decode(buf); v != 3 || string(doc) != `{"a":1}` {
+		t.Fatalf("unexpected %v %s", v, doc)
+	}
+
+	for _, legacy := range []string{`{"a":1}`, `"str"`, `{"_schema":1,"_data":2,"other":3}`, `{"_schema":1,"x":2}`} {
+		if v, doc := Decode([]byte(legacy)); v != 0 || string(doc) != legacy {
+			t.Fatalf("unexpected %v %s for %s", v, doc, legacy)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package repository
+
+import "time"
+
+// Trashed describes a soft deleted entry, which can still be restored until it is purged.
+type Trashed[ID comparable] struct {
+	ID        ID
+	DeletedAt time.Time
+}
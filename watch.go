@@ -0,0 +1,63 @@
+package repository
+
+import "fmt"
+
+// EventType denotes the kind of change, which has been applied to a repository.
+type EventType int
+
+const (
+	Saved   EventType = iota + 1 // Saved denotes an inserted or overwritten entry.
+	Deleted                      // Deleted denotes a removed entry.
+	Cleared                      // Cleared denotes that all entries have been removed at once. The ID is the zero value.
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Saved:
+		return "saved"
+	case Deleted:
+		return "deleted"
+	case Cleared:
+		return "cleared"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event describes a single change of an entry identified by ID.
+type Event[ID comparable] struct {
+	Type EventType
+	ID   ID
+}
+
+// EntityEvent is an Event which optionally carries a copy of the saved entity. The ownership of the entity
+// is handed over to the receiver, like any other entity returned by a CrudRepository.
+type EntityEvent[T any, ID comparable] struct {
+	Event[ID]
+	Entity    T    // Entity is only valid if HasEntity is true.
+	HasEntity bool // HasEntity is only true for Saved events and if WatchOptions.WithEntity was requested.
+}
+
+// OverflowPolicy defines what happens, if the buffered channel of a subscriber is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until the subscriber has consumed enough events or its context is done.
+	// Events are published while the repository holds its lock, so the subscriber must not call back
+	// into the repository from the same goroutine which consumes the channel, otherwise it deadlocks.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest discards the event which does not fit into the buffer anymore.
+	OverflowDropNewest
+	// OverflowDropOldest discards the oldest buffered event to make room for the newest one.
+	OverflowDropOldest
+	// OverflowClose unsubscribes the subscriber and closes its channel, so that it can detect the loss of events
+	// and resubscribe after reloading its state.
+	OverflowClose
+)
+
+// WatchOptions configure a subscription. The zero value is a valid configuration.
+type WatchOptions struct {
+	Buffer     int            // Buffer is the capacity of the channel. Values <= 0 default to 64.
+	Overflow   OverflowPolicy // Overflow defines how to handle slow subscribers.
+	WithEntity bool           // WithEntity requests a decoded copy of the entity for each Saved event, if supported.
+}